@@ -0,0 +1,16 @@
+package thriftpool
+
+import "context"
+
+// Acquire borrows a connection; it is GetContext under the name other pool
+// libraries use. Every connection acquired must be handed back exactly once,
+// with ReleaseConn (Put) to keep it pooled, or CloseErrConn to drop it.
+func (p *ThriftPool) Acquire(ctx context.Context) (*IdleClient, error) {
+	return p.GetContext(ctx)
+}
+
+// ReleaseConn hands back a connection from Acquire; it is Put. Not to be
+// confused with Release, which closes the whole pool.
+func (p *ThriftPool) ReleaseConn(client *IdleClient) error {
+	return p.Put(client)
+}
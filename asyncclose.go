@@ -0,0 +1,52 @@
+package thriftpool
+
+// discard closes a connection dropped on the Get or Put path, in the
+// background if the pool was built WithAsyncClose.
+func (p *ThriftPool) discard(client *IdleClient) error {
+	p.asyncLock.RLock()
+	if p.asyncClose != nil {
+		select {
+		case p.asyncClose <- client:
+			p.asyncLock.RUnlock()
+			return nil
+		default:
+		}
+	}
+	p.asyncLock.RUnlock()
+	return p.closeClient(client)
+}
+
+func (p *ThriftPool) startAsyncClose() {
+	if !p.opts.asyncClose {
+		return
+	}
+	p.asyncLock.Lock()
+	defer p.asyncLock.Unlock()
+	if p.asyncClose != nil {
+		return
+	}
+	queue := make(chan *IdleClient, ASYNCCLOSEQUEUE)
+	done := make(chan struct{})
+	p.asyncClose, p.asyncDone = queue, done
+	go func() {
+		defer close(done)
+		for c := range queue {
+			if err := p.closeClient(c); err != nil {
+				p.logf("thriftpool: async close: %v", err)
+			}
+		}
+	}()
+}
+
+// stopAsyncClose stops the worker after it has closed everything queued.
+func (p *ThriftPool) stopAsyncClose() {
+	p.asyncLock.Lock()
+	queue, done := p.asyncClose, p.asyncDone
+	p.asyncClose, p.asyncDone = nil, nil
+	p.asyncLock.Unlock()
+
+	if queue != nil {
+		close(queue)
+		<-done
+	}
+}
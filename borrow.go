@@ -0,0 +1,135 @@
+package thriftpool
+
+import (
+	"net"
+	"runtime/debug"
+	"sort"
+	"time"
+)
+
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+type borrowInfo struct {
+	id    uint64
+	since time.Time
+	stack []byte
+}
+
+func (p *ThriftPool) logf(format string, v ...interface{}) {
+	if p.opts.logger != nil {
+		p.opts.logger.Printf(format, v...)
+	}
+}
+
+func (p *ThriftPool) borrow(client *IdleClient) {
+	var stack []byte
+	if p.opts.leakDetection {
+		stack = debug.Stack()
+	}
+	p.lock.Lock()
+	now := p.now()
+	p.nextID += 1
+	p.borrowed[client] = &borrowInfo{
+		id:    p.nextID,
+		since: now,
+		stack: stack,
+	}
+	client.lastActive = now
+	p.hadConns = true
+	p.stats.window.observe(uint32(len(p.borrowed)), now)
+	p.lock.Unlock()
+	p.setState(client, StateActive)
+}
+
+// CheckBorrowTime reports connections that have been borrowed for longer than
+// the configured max borrow time, force-closing them if requested.
+func (p *ThriftPool) CheckBorrowTime() {
+	now := p.now()
+	var revoked []*IdleClient
+	p.lock.Lock()
+	if p.opts.maxBorrowTime <= 0 {
+		p.lock.Unlock()
+		return
+	}
+	for c, b := range p.borrowed {
+		held := now.Sub(b.since)
+		if held < p.opts.maxBorrowTime {
+			continue
+		}
+		if b.stack != nil {
+			p.logf("thriftpool: connection %d borrowed for %s, borrowed at:\n%s", b.id, held, b.stack)
+		} else {
+			p.logf("thriftpool: connection %d borrowed for %s", b.id, held)
+		}
+		if p.opts.forceCloseBorrowed {
+			delete(p.borrowed, c)
+			c.revoked = true
+			p.releaseSlotLocked(c.overflow)
+			revoked = append(revoked, c)
+		}
+	}
+	p.unlock()
+
+	for _, c := range revoked {
+		p.closing(c, causeRevoked)
+		p.closeClient(c)
+	}
+}
+
+// onBorrow runs the OnBorrow hook on a connection just lent out, closing it
+// if the hook fails.
+func (p *ThriftPool) onBorrow(client *IdleClient) (*IdleClient, error) {
+	if p.opts.onBorrow == nil {
+		return client, nil
+	}
+	c, err := p.opts.onBorrow(client)
+	if err != nil {
+		p.closeErrConn(client)
+		return nil, err
+	}
+	if c == nil {
+		c = client
+	}
+	return c, nil
+}
+
+// onReturn maps a connection handed back to the pool through the OnReturn
+// hook.
+func (p *ThriftPool) onReturn(client *IdleClient) (*IdleClient, error) {
+	if p.opts.onReturn == nil {
+		return client, nil
+	}
+	c, err := p.opts.onReturn(client)
+	if c == nil {
+		c = client
+	}
+	return c, err
+}
+
+// EachActive calls fn for every borrowed connection, oldest borrow first, with
+// its borrow id, remote address and how long it has been borrowed, until fn
+// returns false. fn runs on a snapshot taken under the lock, so it may call
+// back into the pool.
+func (p *ThriftPool) EachActive(fn func(id uint64, remote net.Addr, borrowedFor time.Duration) bool) {
+	type active struct {
+		id     uint64
+		remote net.Addr
+		since  time.Time
+	}
+	p.lock.RLock()
+	now := p.now()
+	list := make([]active, 0, len(p.borrowed))
+	for c, b := range p.borrowed {
+		list = append(list, active{b.id, c.remoteNetAddr(), b.since})
+	}
+	p.lock.RUnlock()
+
+	sort.Slice(list, func(i, j int) bool { return list[i].id < list[j].id })
+	for _, a := range list {
+		if !fn(a.id, a.remote, now.Sub(a.since)) {
+			return
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package thriftpool
+
+import "time"
+
+type CircuitState int
+
+const (
+	CircuitClosed CircuitState = iota
+	CircuitOpen
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+type breaker struct {
+	state    CircuitState
+	failures uint32
+	openedAt time.Time
+	// probing is set while the one dial let through a half-open circuit
+	// is in flight
+	probing bool
+}
+
+// breakerAllowLocked reports whether a dial may go ahead. Once the cooldown
+// has passed, a single probe dial is let through; the circuit stays shut to
+// everyone else until it resolves.
+func (p *ThriftPool) breakerAllowLocked(now time.Time) bool {
+	if p.opts.breakerThreshold == 0 {
+		return true
+	}
+	switch p.breaker.state {
+	case CircuitClosed:
+		return true
+	case CircuitOpen:
+		if now.Sub(p.breaker.openedAt) < p.opts.breakerCooldown {
+			return false
+		}
+		p.breaker.state = CircuitHalfOpen
+	}
+	if p.breaker.probing {
+		return false
+	}
+	p.breaker.probing = true
+	return true
+}
+
+func (p *ThriftPool) breakerRecordLocked(ok bool, now time.Time) {
+	if p.opts.breakerThreshold == 0 {
+		return
+	}
+	p.breaker.probing = false
+	if ok {
+		p.breaker.state = CircuitClosed
+		p.breaker.failures = 0
+		return
+	}
+	p.breaker.failures += 1
+	if p.breaker.state == CircuitHalfOpen || p.breaker.failures >= p.opts.breakerThreshold {
+		if p.breaker.state != CircuitOpen {
+			p.logf("thriftpool: circuit open after %d consecutive dial failures", p.breaker.failures)
+		}
+		p.breaker.state = CircuitOpen
+		p.breaker.openedAt = now
+	}
+}
+
+// probeAbandonedLocked lets another Get probe when the one that was going to
+// gave up before dialing.
+func (p *ThriftPool) probeAbandonedLocked(probe bool) {
+	if probe {
+		p.breaker.probing = false
+	}
+}
+
+// holdIdleLocked reports whether idle connections are being kept past their
+// idle timeout and lifetime, WithServeIdleWhenOpen, as they couldn't be
+// replaced.
+func (p *ThriftPool) holdIdleLocked(now time.Time) bool {
+	return p.opts.serveIdleWhenOpen && p.circuitStateLocked(now) != CircuitClosed
+}
+
+func (p *ThriftPool) CircuitState() CircuitState {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return p.circuitStateLocked(p.now())
+}
+
+func (p *ThriftPool) circuitStateLocked(now time.Time) CircuitState {
+	if p.breaker.state == CircuitOpen && now.Sub(p.breaker.openedAt) >= p.opts.breakerCooldown {
+		return CircuitHalfOpen
+	}
+	return p.breaker.state
+}
@@ -0,0 +1,89 @@
+package thriftpool_test
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lehaisonmath6/thriftpool"
+	"github.com/lehaisonmath6/thriftpool/thriftpooltest"
+)
+
+func TestHalfOpenLetsOneProbeThrough(t *testing.T) {
+	for _, probeOK := range []bool{true, false} {
+		d := &thriftpooltest.Dialer{}
+		var mu sync.Mutex
+		var dialErr error = errors.New("refused")
+		probing := make(chan struct{}, 1)
+		resolve := make(chan struct{})
+		dial := func(ip, port string, timeout time.Duration) (*thriftpool.IdleClient, error) {
+			mu.Lock()
+			err := dialErr
+			mu.Unlock()
+			if err == nil {
+				probing <- struct{}{}
+				<-resolve
+				if !probeOK {
+					err = errors.New("still refused")
+				}
+			}
+			if err != nil {
+				return nil, err
+			}
+			return d.Dial(ip, port, timeout)
+		}
+		p := thriftpool.NewThriftPool("127.0.0.1", "9090", 10, 1, 10, dial, nil,
+			thriftpool.WithCircuitBreaker(1, 20*time.Millisecond))
+
+		if _, err := p.Get(); err == nil {
+			t.Fatal("Get succeeded with a failing dial")
+		}
+		if s := p.CircuitState(); s != thriftpool.CircuitOpen {
+			t.Fatalf("circuit %v after the threshold, want open", s)
+		}
+		mu.Lock()
+		dialErr = nil
+		mu.Unlock()
+		time.Sleep(30 * time.Millisecond)
+
+		probe := make(chan error, 1)
+		go func() {
+			c, err := p.Get()
+			if err == nil {
+				p.Put(c)
+			}
+			probe <- err
+		}()
+		<-probing
+		// everyone arriving while the probe is in flight is turned away
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := p.Get(); err != thriftpool.ErrCircuitOpen {
+					t.Errorf("probeOK=%v: Get during the probe = %v, want ErrCircuitOpen", probeOK, err)
+				}
+			}()
+		}
+		wg.Wait()
+		close(resolve)
+
+		err := <-probe
+		want, wantDials := thriftpool.CircuitClosed, 1
+		if !probeOK {
+			want, wantDials = thriftpool.CircuitOpen, 0
+		}
+		if (err == nil) != probeOK {
+			t.Errorf("probeOK=%v: probe Get = %v", probeOK, err)
+		}
+		if s := p.CircuitState(); s != want {
+			t.Errorf("probeOK=%v: circuit %v after the probe, want %v", probeOK, s, want)
+		}
+		if n := d.Dials(); n != wantDials {
+			t.Errorf("probeOK=%v: %d connections dialed, want %d", probeOK, n, wantDials)
+		}
+		p.Release()
+	}
+}
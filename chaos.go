@@ -0,0 +1,64 @@
+package thriftpool
+
+// SnapshotIdle returns the idle connections in borrow order without taking
+// them out of the pool. It is meant for tests and fault injection: the
+// connections still belong to the pool and must not be used, only handed
+// back to InjectIdle.
+func (p *ThriftPool) SnapshotIdle() []*IdleClient {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	idle := make([]*IdleClient, 0, p.idle.Len())
+	p.idle.Each(func(e *IdleEntry) bool {
+		idle = append(idle, e.Client)
+		return true
+	})
+	return idle
+}
+
+// InjectIdle replaces the pool's idle connections with clients, for tests
+// and fault injection, e.g. restoring a SnapshotIdle or planting dead
+// connections for Get to weed out. Idle connections not among clients are
+// closed, and clients that don't fit under MaxConn beside the borrowed ones
+// are closed too. It returns how many of clients were pooled.
+func (p *ThriftPool) InjectIdle(clients []*IdleClient) int {
+	injected := make(map[*IdleClient]bool, len(clients))
+	for _, c := range clients {
+		injected[c] = true
+		if c.tracked != p {
+			untrack(c)
+		}
+	}
+
+	p.lock.Lock()
+	var closing []*IdleClient
+	for e := p.idle.Take(nil); e != nil; e = p.idle.Take(nil) {
+		p.releaseSlotLocked(false)
+		if !injected[e.Client] {
+			closing = append(closing, e.Client)
+		}
+	}
+	n := 0
+	for _, c := range clients {
+		if p.closed || p.fullLocked() || !p.takeSlotLocked() {
+			closing = append(closing, c)
+			continue
+		}
+		c.home = p
+		if c.clock == nil {
+			c.clock = p.now
+		}
+		c.overflow = false
+		if c.tracked != p {
+			p.trackLocked(c)
+		}
+		p.pushIdle(c)
+		n++
+	}
+	p.unlock()
+
+	for _, c := range closing {
+		p.closing(c, causeRetired)
+		p.closeClient(c)
+	}
+	return n
+}
@@ -0,0 +1,53 @@
+package thriftpool
+
+import (
+	"errors"
+	"io"
+	"net"
+
+	"github.com/apache/thrift/lib/go/thrift"
+)
+
+// DefaultClassifyError is the classifier PutErr uses unless the pool was
+// built WithErrorClassifier. It treats EOFs, transport and protocol errors
+// and non-temporary network errors as poison, since the connection's stream
+// can't be trusted after them, and anything else, such as application
+// exceptions, as safe to reuse the connection after.
+func DefaultClassifyError(err error) (poison bool) {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var te thrift.TTransportException
+	var pe thrift.TProtocolException
+	var ae thrift.TApplicationException
+	switch {
+	case errors.As(err, &te), errors.As(err, &pe):
+		return true
+	case errors.As(err, &ae):
+		return false
+	}
+	var ne net.Error
+	if errors.As(err, &ne) {
+		return !ne.Temporary()
+	}
+	return false
+}
+
+// PutErr returns client after an RPC that finished with rpcErr: the
+// connection is closed if the pool's error classifier says rpcErr poisoned
+// it, and pooled otherwise. A non-nil rpcErr that didn't poison the
+// connection still counts against WithMaxConnErrors, as PutResult false does.
+func (p *ThriftPool) PutErr(client *IdleClient, rpcErr error) error {
+	classify := p.opts.classifyError
+	if classify == nil {
+		classify = DefaultClassifyError
+	}
+	if classify(rpcErr) {
+		p.CloseErrConn(client)
+		return nil
+	}
+	return p.PutResult(client, rpcErr == nil)
+}
@@ -0,0 +1,49 @@
+package thriftpool_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/apache/thrift/lib/go/thrift"
+	"github.com/lehaisonmath6/thriftpool"
+	"github.com/lehaisonmath6/thriftpool/thriftpooltest"
+)
+
+func TestPutErrCountsTowardMaxConnErrors(t *testing.T) {
+	d := &thriftpooltest.Dialer{}
+	p := thriftpool.NewThriftPool("127.0.0.1", "9090", 1, 1, 10, d.Dial, nil,
+		thriftpool.WithMaxConnErrors(2))
+	defer p.Release()
+
+	appErr := thrift.NewTApplicationException(thrift.INTERNAL_ERROR, "handler failed")
+	for i := 0; i < 2; i++ {
+		c, err := p.Get()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := p.PutErr(c, appErr); err != nil {
+			t.Fatalf("PutErr %d: %v", i, err)
+		}
+	}
+	if n := p.Stats().Idle; n != 0 {
+		t.Errorf("%d idle after two application errors with WithMaxConnErrors(2), want 0", n)
+	}
+	if n := d.Dials(); n != 1 {
+		t.Errorf("%d dials, want the one connection reused until its second error", n)
+	}
+}
+
+func TestDefaultClassifyErrorUnwraps(t *testing.T) {
+	for _, tc := range []struct {
+		err    error
+		poison bool
+	}{
+		{fmt.Errorf("call: %w", thrift.NewTTransportException(thrift.TIMED_OUT, "timeout")), true},
+		{fmt.Errorf("call: %w", thrift.NewTProtocolException(fmt.Errorf("bad frame"))), true},
+		{fmt.Errorf("call: %w", thrift.NewTApplicationException(thrift.INTERNAL_ERROR, "failed")), false},
+	} {
+		if got := thriftpool.DefaultClassifyError(tc.err); got != tc.poison {
+			t.Errorf("DefaultClassifyError(%v) = %v, want %v", tc.err, got, tc.poison)
+		}
+	}
+}
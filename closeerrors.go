@@ -0,0 +1,47 @@
+package thriftpool
+
+import "sync/atomic"
+
+// CloseErrors returns the channel on which a pool built WithCloseErrors
+// publishes every error its close function returns, wherever the close
+// happens. Errors that find the channel full are dropped and counted in
+// Stats.CloseErrorsDropped. The channel is closed by Release, and Recover
+// opens a new one. It is nil without WithCloseErrors.
+func (p *ThriftPool) CloseErrors() <-chan error {
+	p.eventLock.RLock()
+	defer p.eventLock.RUnlock()
+	return p.closeErrs
+}
+
+func (p *ThriftPool) publishCloseError(err error) {
+	p.eventLock.RLock()
+	defer p.eventLock.RUnlock()
+	if p.closeErrs == nil {
+		return
+	}
+	select {
+	case p.closeErrs <- err:
+	default:
+		atomic.AddUint64(&p.stats.closeErrsDropped, 1)
+	}
+}
+
+func (p *ThriftPool) closeCloseErrors() {
+	p.eventLock.Lock()
+	if p.closeErrs != nil {
+		close(p.closeErrs)
+		p.closeErrs = nil
+	}
+	p.eventLock.Unlock()
+}
+
+func (p *ThriftPool) openCloseErrors() {
+	if p.opts.closeErrBuffer <= 0 {
+		return
+	}
+	p.eventLock.Lock()
+	if p.closeErrs == nil {
+		p.closeErrs = make(chan error, p.opts.closeErrBuffer)
+	}
+	p.eventLock.Unlock()
+}
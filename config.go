@@ -0,0 +1,152 @@
+package thriftpool
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrInvalidConfig = errors.New("ErrInvalidConfig")
+
+// Config holds the pool's runtime-tunable settings.
+type Config struct {
+	// MaxConn must be positive, and is ignored when Unlimited is set.
+	MaxConn     uint32
+	Unlimited   bool
+	ConnTimeout time.Duration
+	// DialTimeout bounds dials instead of ConnTimeout when positive.
+	DialTimeout time.Duration
+	IdleTimeout time.Duration
+	MaxLifetime time.Duration
+
+	MinIdle       uint32
+	MaxIdle       uint32
+	CheckInterval time.Duration
+
+	// OverflowBurst is the number of temporary connections allowed past
+	// MaxConn, see Overflow. With neither it nor Block set, Gets are rejected
+	// once MaxConn is reached.
+	OverflowBurst uint32
+	Block         bool
+
+	// IdleSampleFraction is the fraction of idle connections validated on
+	// each reaper tick, see WithIdleSampling.
+	IdleSampleFraction float64
+
+	ValidateAfterIdle  time.Duration
+	MaxBorrowTime      time.Duration
+	ForceCloseBorrowed bool
+}
+
+func (c Config) validate() error {
+	switch {
+	case c.ConnTimeout < 0 || c.DialTimeout < 0 || c.IdleTimeout < 0 || c.MaxLifetime < 0 || c.ValidateAfterIdle < 0 || c.MaxBorrowTime < 0:
+		return fmt.Errorf("%w: negative duration", ErrInvalidConfig)
+	case c.MaxConn == 0 && !c.Unlimited:
+		return fmt.Errorf("%w: maxConn must be positive", ErrInvalidConfig)
+	case c.Block && c.OverflowBurst > 0:
+		return fmt.Errorf("%w: Block and OverflowBurst are exclusive", ErrInvalidConfig)
+	case c.CheckInterval <= 0:
+		return fmt.Errorf("%w: check interval must be positive", ErrInvalidConfig)
+	case c.IdleSampleFraction < 0 || c.IdleSampleFraction > 1:
+		return fmt.Errorf("%w: idle sample fraction %v not in [0, 1]", ErrInvalidConfig, c.IdleSampleFraction)
+	case !c.Unlimited && c.MinIdle > c.MaxConn:
+		return fmt.Errorf("%w: minIdle %d exceeds maxConn %d", ErrInvalidConfig, c.MinIdle, c.MaxConn)
+	case c.MaxIdle > 0 && c.MinIdle > c.MaxIdle:
+		return fmt.Errorf("%w: minIdle %d exceeds maxIdle %d", ErrInvalidConfig, c.MinIdle, c.MaxIdle)
+	}
+	return nil
+}
+
+func (p *ThriftPool) Config() Config {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return p.configLocked()
+}
+
+func (p *ThriftPool) configLocked() Config {
+	return p.opts.config(p.maxConn, p.connTimeout, p.idleTimeout)
+}
+
+func (o *options) config(maxConn uint32, connTimeout, idleTimeout time.Duration) Config {
+	return Config{
+		MaxConn:            maxConn,
+		Unlimited:          o.unlimited,
+		ConnTimeout:        connTimeout,
+		DialTimeout:        o.dialTimeout,
+		IdleTimeout:        idleTimeout,
+		MaxLifetime:        o.maxLifetime,
+		MinIdle:            o.minIdle,
+		MaxIdle:            o.maxIdle,
+		CheckInterval:      o.checkInterval,
+		OverflowBurst:      o.overflow.burst,
+		Block:              o.overflow.block,
+		IdleSampleFraction: o.sampleFraction,
+		ValidateAfterIdle:  o.validateAfterIdle,
+		MaxBorrowTime:      o.maxBorrowTime,
+		ForceCloseBorrowed: o.forceCloseBorrowed,
+	}
+}
+
+// Apply validates cfg and installs all of it at once. Idle connections beyond
+// a lowered MaxConn or MaxIdle are closed, and a raised MinIdle is filled in
+// the background.
+func (p *ThriftPool) Apply(cfg Config) error {
+	p.applyLock.Lock()
+	defer p.applyLock.Unlock()
+	return p.apply(cfg)
+}
+
+// SetMaxConn changes MaxConn alone, as Apply would.
+func (p *ThriftPool) SetMaxConn(maxConn uint32) error {
+	p.applyLock.Lock()
+	defer p.applyLock.Unlock()
+	cfg := p.Config()
+	cfg.MaxConn = maxConn
+	return p.apply(cfg)
+}
+
+func (p *ThriftPool) apply(cfg Config) error {
+	if err := cfg.validate(); err != nil {
+		return err
+	}
+
+	p.lock.Lock()
+	raisedMinIdle := cfg.MinIdle > p.opts.minIdle
+	oldMax := p.maxConn
+	p.maxConn = cfg.MaxConn
+	p.opts.unlimited = cfg.Unlimited
+	p.connTimeout = cfg.ConnTimeout
+	p.opts.dialTimeout = cfg.DialTimeout
+	p.idleTimeout = cfg.IdleTimeout
+	p.opts.maxLifetime = cfg.MaxLifetime
+	p.opts.minIdle = cfg.MinIdle
+	p.opts.maxIdle = cfg.MaxIdle
+	p.opts.checkInterval = cfg.CheckInterval
+	p.opts.overflow = OverflowPolicy{burst: cfg.OverflowBurst, block: cfg.Block}
+	p.opts.sampleFraction = cfg.IdleSampleFraction
+	p.opts.validateAfterIdle = cfg.ValidateAfterIdle
+	p.opts.maxBorrowTime = cfg.MaxBorrowTime
+	p.opts.forceCloseBorrowed = cfg.ForceCloseBorrowed
+
+	var trimmed []*IdleClient
+	for p.idle.Len() > 0 && (p.overMaxLocked() ||
+		(p.opts.maxIdle > 0 && uint32(p.idle.Len()) > p.opts.maxIdle)) {
+		trimmed = append(trimmed, p.idle.Take(nil).Client)
+		p.releaseSlotLocked(false)
+	}
+	p.broadcastWaitersLocked()
+	p.unlock()
+
+	if fn := p.opts.onResize; fn != nil && cfg.MaxConn != oldMax {
+		fn(oldMax, cfg.MaxConn)
+	}
+	for _, c := range trimmed {
+		p.closing(c, causeSurplus)
+		p.closeClient(c)
+	}
+	if raisedMinIdle {
+		go p.fillIdle()
+	}
+	return nil
+}
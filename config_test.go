@@ -0,0 +1,70 @@
+package thriftpool_test
+
+import (
+	"errors"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/lehaisonmath6/thriftpool"
+	"github.com/lehaisonmath6/thriftpool/thriftpooltest"
+)
+
+func TestZeroMaxConnRejected(t *testing.T) {
+	resized := 0
+	p := thriftpool.NewThriftPool("127.0.0.1", "9090", 2, 1, 10,
+		thriftpooltest.FakeDial, thriftpooltest.FakeClose,
+		thriftpool.WithOnResize(func(old, new uint32) { resized++ }))
+	defer p.Release()
+
+	if err := p.SetMaxConn(0); !errors.Is(err, thriftpool.ErrInvalidConfig) {
+		t.Errorf("SetMaxConn(0) = %v, want ErrInvalidConfig", err)
+	}
+	cfg := p.Config()
+	cfg.MaxConn = 0
+	if err := p.Apply(cfg); !errors.Is(err, thriftpool.ErrInvalidConfig) {
+		t.Errorf("Apply with MaxConn 0 = %v, want ErrInvalidConfig", err)
+	}
+	if resized != 0 {
+		t.Errorf("OnResize fired %d times for rejected changes", resized)
+	}
+	if n := p.Config().MaxConn; n != 2 {
+		t.Errorf("MaxConn %d after rejected changes, want 2", n)
+	}
+	if _, err := p.Get(); err != nil {
+		t.Errorf("Get after rejected changes: %v", err)
+	}
+
+	cfg.Unlimited = true
+	if err := p.Apply(cfg); err != nil {
+		t.Errorf("Apply with MaxConn 0 and Unlimited: %v", err)
+	}
+}
+
+func TestNewRejectsInvalidConfig(t *testing.T) {
+	dial := thriftpool.WithDial(thriftpooltest.FakeDial)
+	for _, tc := range []struct {
+		name string
+		opts []thriftpool.Option
+	}{
+		{"no dial", []thriftpool.Option{thriftpool.WithMaxConn(1), thriftpool.WithAddrs("127.0.0.1:9090")}},
+		{"no maxConn", []thriftpool.Option{dial, thriftpool.WithAddrs("127.0.0.1:9090")}},
+		{"no host or resolver", []thriftpool.Option{dial, thriftpool.WithMaxConn(1)}},
+		{"empty address", []thriftpool.Option{dial, thriftpool.WithMaxConn(1), thriftpool.WithAddrs(":")}},
+		{"negative timeout", []thriftpool.Option{dial, thriftpool.WithMaxConn(1),
+			thriftpool.WithAddrs("127.0.0.1:9090"), thriftpool.WithConnTimeout(-time.Second)}},
+	} {
+		before := runtime.NumGoroutine()
+		p, err := thriftpool.New(tc.opts...)
+		if !errors.Is(err, thriftpool.ErrInvalidConfig) {
+			t.Errorf("%s: New = %v, want ErrInvalidConfig", tc.name, err)
+		}
+		if p != nil {
+			t.Errorf("%s: New returned a pool with its error", tc.name)
+			p.Release()
+		}
+		if n := runtime.NumGoroutine(); n > before {
+			t.Errorf("%s: %d goroutines left running by a rejected New", tc.name, n-before)
+		}
+	}
+}
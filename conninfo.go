@@ -0,0 +1,62 @@
+package thriftpool
+
+import (
+	"net"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// ConnInfo describes one of the pool's connections for diagnostics.
+type ConnInfo struct {
+	Remote   net.Addr
+	Borrowed bool
+	// Age is zero if the pool doesn't know when the connection was dialed.
+	Age time.Duration
+	// IdleFor and Usage are zero for borrowed connections, whose usage
+	// their borrower may be changing.
+	IdleFor time.Duration
+	Usage   int64
+	// Errors is how many times the connection was returned with PutResult
+	// false, see WithMaxConnErrors.
+	Errors uint32
+}
+
+// EachConn calls fn for every idle and borrowed connection, oldest first,
+// until fn returns false. Like EachActive, fn runs on a snapshot and may call
+// back into the pool.
+func (p *ThriftPool) EachConn(fn func(ConnInfo) bool) {
+	p.lock.RLock()
+	now := p.now()
+	info := func(c *IdleClient) ConnInfo {
+		ci := ConnInfo{
+			Remote: c.remoteNetAddr(),
+			Errors: atomic.LoadUint32(&c.errs),
+		}
+		if !c.createdAt.IsZero() {
+			ci.Age = now.Sub(c.createdAt)
+		}
+		return ci
+	}
+	list := make([]ConnInfo, 0, p.idle.Len()+len(p.borrowed))
+	p.idle.Each(func(e *IdleEntry) bool {
+		ci := info(e.Client)
+		ci.IdleFor = now.Sub(e.Since)
+		ci.Usage = e.Client.usage
+		list = append(list, ci)
+		return true
+	})
+	for c := range p.borrowed {
+		ci := info(c)
+		ci.Borrowed = true
+		list = append(list, ci)
+	}
+	p.lock.RUnlock()
+
+	sort.SliceStable(list, func(i, j int) bool { return list[i].Age > list[j].Age })
+	for _, ci := range list {
+		if !fn(ci) {
+			return
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package thriftpool
+
+// ConnState is a stage in a connection's life, reported to the WithConnState
+// callback like net/http.Server's ConnState.
+type ConnState int
+
+const (
+	// StateNew is a connection just dialed.
+	StateNew ConnState = iota
+	// StateIdle is a connection put in the idle store.
+	StateIdle
+	// StateActive is a connection lent out by a Get.
+	StateActive
+	// StateClosed is a connection closed by the pool.
+	StateClosed
+)
+
+func (s ConnState) String() string {
+	switch s {
+	case StateNew:
+		return "new"
+	case StateIdle:
+		return "idle"
+	case StateActive:
+		return "active"
+	case StateClosed:
+		return "closed"
+	}
+	return "unknown"
+}
+
+// setState reports a connection's new state. It must be called without the
+// pool lock held.
+func (p *ThriftPool) setState(client *IdleClient, state ConnState) {
+	if p.opts.connState != nil {
+		p.opts.connState(client, state)
+	}
+}
@@ -0,0 +1,18 @@
+package thriftpool
+
+import "context"
+
+type poolKey struct{}
+
+// NewContext returns a copy of ctx carrying p, for handlers further down to
+// get back with FromContext. It is only a carrier: the pool's lifecycle is
+// unaffected, and it is not released when ctx is done.
+func NewContext(ctx context.Context, p *ThriftPool) context.Context {
+	return context.WithValue(ctx, poolKey{}, p)
+}
+
+// FromContext returns the pool NewContext stored in ctx, if any.
+func FromContext(ctx context.Context) (*ThriftPool, bool) {
+	p, ok := ctx.Value(poolKey{}).(*ThriftPool)
+	return p, ok && p != nil
+}
@@ -0,0 +1,33 @@
+package thriftpool_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/lehaisonmath6/thriftpool"
+	"github.com/lehaisonmath6/thriftpool/thriftpooltest"
+)
+
+func TestContextRoundTrip(t *testing.T) {
+	if _, ok := thriftpool.FromContext(context.Background()); ok {
+		t.Fatal("FromContext found a pool in an empty context")
+	}
+	if _, ok := thriftpool.FromContext(thriftpool.NewContext(context.Background(), nil)); ok {
+		t.Error("FromContext reported a nil pool")
+	}
+
+	p := thriftpool.NewThriftPool("127.0.0.1", "9090", 1, 1, 10,
+		thriftpooltest.FakeDial, thriftpooltest.FakeClose)
+	defer p.Release()
+	ctx, cancel := context.WithCancel(thriftpool.NewContext(context.Background(), p))
+	got, ok := thriftpool.FromContext(ctx)
+	if !ok || got != p {
+		t.Fatalf("FromContext = %p, %v, want %p", got, ok, p)
+	}
+
+	// ending the context leaves the pool alone
+	cancel()
+	if s := p.State(); s != thriftpool.PoolOpen {
+		t.Errorf("pool %v after its context was cancelled, want open", s)
+	}
+}
@@ -0,0 +1,34 @@
+package thriftpool
+
+import "context"
+
+// Do borrows a connection, runs fn on it and returns it whatever happens:
+// with PutErr for fn's error, or to CloseErrConn if fn panics. It returns the
+// Get error or fn's.
+func (p *ThriftPool) Do(fn func(c *IdleClient) error) error {
+	return p.DoContext(p.baseContext(), func(_ context.Context, c *IdleClient) error {
+		return fn(c)
+	})
+}
+
+// DoContext is Do bounded by ctx, which GetContext waits on and fn is given.
+// A connection whose ctx ended while fn ran is closed instead of pooled,
+// since the RPC it carried was likely cut off mid-stream and left it in an
+// unknown state.
+func (p *ThriftPool) DoContext(ctx context.Context, fn func(ctx context.Context, c *IdleClient) error) (err error) {
+	c, err := p.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	done := false
+	defer func() {
+		if !done || ctx.Err() != nil {
+			p.CloseErrConn(c)
+			return
+		}
+		p.PutErr(c, err)
+	}()
+	err = fn(ctx, c)
+	done = true
+	return err
+}
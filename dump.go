@@ -0,0 +1,86 @@
+package thriftpool
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// PoolDump is a consistent snapshot of a pool's state for debugging.
+type PoolDump struct {
+	Time    time.Time
+	Addrs   []string
+	Config  Config
+	State   PoolState
+	Circuit CircuitState
+	Stats   Stats
+
+	Waiters  int
+	Borrowed int
+	Dialing  uint32
+
+	IdleByAddr map[string]uint32
+	// OldestIdle is how long the longest idle connection has been idle, and
+	// OldestBorrow how long the longest running borrow has been going on.
+	OldestIdle   time.Duration
+	OldestBorrow time.Duration
+}
+
+// Dump collects everything the pool's accessors report in one lock
+// acquisition.
+func (p *ThriftPool) Dump() PoolDump {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	now := p.now()
+	d := PoolDump{
+		Time:       now,
+		Addrs:      append([]string(nil), p.addrs...),
+		Config:     p.configLocked(),
+		State:      p.stateLocked(),
+		Circuit:    p.circuitStateLocked(now),
+		Stats:      p.statsLocked(),
+		Waiters:    p.waitersLocked(),
+		Borrowed:   len(p.borrowed),
+		Dialing:    p.dialing,
+		IdleByAddr: p.idleByAddrLocked(),
+	}
+	p.idle.Each(func(e *IdleEntry) bool {
+		if age := now.Sub(e.Since); age > d.OldestIdle {
+			d.OldestIdle = age
+		}
+		return true
+	})
+	for _, info := range p.borrowed {
+		if age := now.Sub(info.since); age > d.OldestBorrow {
+			d.OldestBorrow = age
+		}
+	}
+	return d
+}
+
+func (d PoolDump) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "pool %s at %s: %s, circuit %s\n", strings.Join(d.Addrs, ","), d.Time.Format(time.RFC3339), d.State, d.Circuit)
+	fmt.Fprintf(&b, "  config: %+v\n", d.Config)
+	fmt.Fprintf(&b, "  conns: open=%d idle=%d in_use=%d overflow=%d borrowed=%d dialing=%d waiters=%d\n",
+		d.Stats.OpenConnections, d.Stats.Idle, d.Stats.InUse, d.Stats.Overflow, d.Borrowed, d.Dialing, d.Waiters)
+	fmt.Fprintf(&b, "  oldest: idle=%s borrow=%s\n", d.OldestIdle, d.OldestBorrow)
+
+	addrs := make([]string, 0, len(d.IdleByAddr))
+	for addr := range d.IdleByAddr {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+	for _, addr := range addrs {
+		fmt.Fprintf(&b, "  idle %s: %d\n", addr, d.IdleByAddr[addr])
+	}
+
+	s := d.Stats
+	fmt.Fprintf(&b, "  counters: gets=%d hits=%d misses=%d timeouts=%d dial_errors=%d evictions=%d events_dropped=%d\n",
+		s.Gets, s.Hits, s.Misses, s.Timeouts, s.DialErrors, s.Evictions, s.EventsDropped)
+	fmt.Fprintf(&b, "  waits: count=%d duration=%s; closed: max_idle=%d max_idle_time=%d max_lifetime=%d",
+		s.WaitCount, s.WaitDuration, s.MaxIdleClosed, s.MaxIdleTimeClosed, s.MaxLifetimeClosed)
+	return b.String()
+}
@@ -0,0 +1,37 @@
+package thriftpool_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lehaisonmath6/thriftpool"
+	"github.com/lehaisonmath6/thriftpool/thriftpooltest"
+)
+
+func TestDumpCountsTenantWaiters(t *testing.T) {
+	d := &thriftpooltest.Dialer{}
+	p := thriftpool.NewThriftPool("127.0.0.1", "9090", 1, 1, 10, d.Dial, nil,
+		thriftpool.WithOverflowPolicy(thriftpool.Block), thriftpool.WithTenantKey(tenantOf, false))
+	defer p.Release()
+
+	c, err := p.GetContext(withTenant("a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if c, err := p.GetContext(withTenant("b")); err == nil {
+			p.Tenant("b").Put(c)
+		}
+	}()
+	// tenant b queues on the limit it shares with a
+	for p.Stats().Waiters == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	if n := p.Dump().Waiters; n != 1 {
+		t.Errorf("Dump reports %d waiters, want 1", n)
+	}
+	p.Tenant("a").CloseErrConn(c)
+	<-done
+}
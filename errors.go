@@ -0,0 +1,66 @@
+package thriftpool
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"github.com/apache/thrift/lib/go/thrift"
+)
+
+// DialError is returned when dialing a new connection fails, wrapping the
+// error from the dial function or from the WithOnConnect hook.
+type DialError struct {
+	Addr string
+	Err  error
+}
+
+func (e *DialError) Error() string {
+	return "thriftpool: dial " + e.Addr + ": " + e.Err.Error()
+}
+
+func (e *DialError) Unwrap() error {
+	return e.Err
+}
+
+// IsTransient reports whether a Get that failed with err may succeed if
+// retried. Saturation, of the pool, its addresses or its waiter queue, a
+// broken idle connection, an open circuit and the dial rate limit are
+// transient; a released pool, an invalid connection and the caller's own
+// context ending are not. A DialError is transient if its cause is a
+// temporary or timed out network error or a thrift transport timeout.
+func IsTransient(err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, ErrOverMax), errors.Is(err, ErrSocketDisconnect), errors.Is(err, ErrCircuitOpen),
+		errors.Is(err, ErrDialRateLimited), errors.Is(err, ErrAddrFull), errors.Is(err, ErrTooManyWaiters):
+		return true
+	case errors.Is(err, ErrPoolClosed), errors.Is(err, ErrInvalidConn):
+		return false
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return false
+	}
+
+	var de *DialError
+	if !errors.As(err, &de) {
+		return false
+	}
+	if isTimeout(de.Err) {
+		return true
+	}
+	cause := de.Err
+	// thrift's transport exceptions don't implement Unwrap
+	var te thrift.TTransportException
+	if errors.As(cause, &te) && te.Err() != nil {
+		cause = te.Err()
+	}
+	var ne net.Error
+	return errors.As(cause, &ne) && (ne.Timeout() || ne.Temporary())
+}
+
+// isContextErr reports whether err is a context ending rather than a failure
+// of the pool or the server.
+func isContextErr(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
@@ -0,0 +1,95 @@
+package thriftpool
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+type EventType int
+
+const (
+	EventDialSucceeded EventType = iota
+	EventDialFailed
+	EventReused
+	EventEvicted
+	EventReturned
+	EventSaturated
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventDialSucceeded:
+		return "DialSucceeded"
+	case EventDialFailed:
+		return "DialFailed"
+	case EventReused:
+		return "Reused"
+	case EventEvicted:
+		return "Evicted"
+	case EventReturned:
+		return "Returned"
+	case EventSaturated:
+		return "Saturated"
+	}
+	return "Unknown"
+}
+
+type PoolEvent struct {
+	Type EventType
+	Time time.Time
+	// Addr is the remote address of the connection concerned, if any.
+	Addr string
+	// Err is set for EventDialFailed.
+	Err error
+}
+
+// Events returns the pool's event stream, or nil unless the pool was built
+// WithEvents. Events are dropped rather than blocking the pool when the
+// channel is full. Release closes the channel; after Recover, call Events
+// again for the new one.
+func (p *ThriftPool) Events() <-chan PoolEvent {
+	p.eventLock.RLock()
+	defer p.eventLock.RUnlock()
+	return p.events
+}
+
+func (p *ThriftPool) emit(t EventType, client *IdleClient, err error) {
+	if p.opts.eventBuffer <= 0 {
+		return
+	}
+	ev := PoolEvent{Type: t, Time: p.now(), Err: err}
+	if client != nil {
+		ev.Addr = client.remoteAddr()
+	}
+
+	p.eventLock.RLock()
+	defer p.eventLock.RUnlock()
+	if p.events == nil {
+		return
+	}
+	select {
+	case p.events <- ev:
+	default:
+		atomic.AddUint64(&p.stats.eventsDropped, 1)
+	}
+}
+
+func (p *ThriftPool) closeEvents() {
+	p.eventLock.Lock()
+	if p.events != nil {
+		close(p.events)
+		p.events = nil
+	}
+	p.eventLock.Unlock()
+}
+
+func (p *ThriftPool) openEvents() {
+	if p.opts.eventBuffer <= 0 {
+		return
+	}
+	p.eventLock.Lock()
+	if p.events == nil {
+		p.events = make(chan PoolEvent, p.opts.eventBuffer)
+	}
+	p.eventLock.Unlock()
+}
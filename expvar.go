@@ -0,0 +1,24 @@
+package thriftpool
+
+import (
+	"expvar"
+	"fmt"
+	"sync"
+)
+
+var expvarLock sync.Mutex
+
+// PublishExpvar publishes p's Stats under name in expvar, so they show up on
+// /debug/vars. It fails if name is taken, by another pool or anything else.
+// expvar can't unpublish, so a released pool keeps reporting its final stats.
+func (p *ThriftPool) PublishExpvar(name string) error {
+	expvarLock.Lock()
+	defer expvarLock.Unlock()
+	if expvar.Get(name) != nil {
+		return fmt.Errorf("thriftpool: expvar %q already published", name)
+	}
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		return p.Stats()
+	}))
+	return nil
+}
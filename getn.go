@@ -0,0 +1,45 @@
+package thriftpool
+
+import (
+	"context"
+	"fmt"
+)
+
+// GetN borrows n connections as a batch: it returns n healthy connections or
+// none. A connection that turns out dead is replaced without giving up the
+// rest of the batch; any other failure returns what was already borrowed
+// and fails. Under the Block policy the batch holds its connections while
+// waiting for the remaining ones, so concurrent batches that together need
+// more than MaxConn wait until ctx ends.
+func (p *ThriftPool) GetN(ctx context.Context, n int) ([]*IdleClient, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+	p.lock.RLock()
+	maxConn, unlimited := p.maxConn, p.opts.unlimited
+	// each idle connection Get could find dead is worth a retry, and each
+	// slot one more for a dial coming back disconnected
+	retries := p.idle.Len() + n
+	p.lock.RUnlock()
+	if !unlimited && uint32(n) > maxConn {
+		return nil, fmt.Errorf("%w: batch of %d exceeds maxConn %d", ErrOverMax, n, maxConn)
+	}
+
+	batch := make([]*IdleClient, 0, n)
+	for len(batch) < n {
+		c, err := p.GetContext(ctx)
+		if err == ErrSocketDisconnect && retries > 0 {
+			// a dead connection, its slot is already released
+			retries--
+			continue
+		}
+		if err != nil {
+			for _, c := range batch {
+				p.Put(c)
+			}
+			return nil, err
+		}
+		batch = append(batch, c)
+	}
+	return batch, nil
+}
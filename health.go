@@ -0,0 +1,51 @@
+package thriftpool
+
+// HealthStatus is the pool's own assessment of whether it can serve.
+type HealthStatus int
+
+const (
+	// Healthy pools are open with the circuit closed and dials mostly
+	// succeeding.
+	Healthy HealthStatus = iota
+	// Degraded pools are probing a half-open circuit or failing dials at
+	// or above the WithHealthThresholds rate.
+	Degraded
+	// Unhealthy pools are released or have an open circuit.
+	Unhealthy
+)
+
+func (s HealthStatus) String() string {
+	switch s {
+	case Healthy:
+		return "healthy"
+	case Degraded:
+		return "degraded"
+	case Unhealthy:
+		return "unhealthy"
+	}
+	return "unknown"
+}
+
+// Health combines the pool's state, its circuit breaker and its recent dial
+// error rate. It is cheap enough to poll from a health check.
+func (p *ThriftPool) Health() HealthStatus {
+	p.lock.RLock()
+	now := p.now()
+	closed := p.closed
+	circuit := p.circuitStateLocked(now)
+	p.lock.RUnlock()
+
+	switch {
+	case closed || circuit == CircuitOpen:
+		return Unhealthy
+	case circuit == CircuitHalfOpen:
+		return Degraded
+	}
+
+	n := p.stats.window.sum(now, p.opts.healthWindow)
+	failed, ok := n[statDialErrors], n[statDials]
+	if failed > 0 && float64(failed) >= p.opts.healthErrorRate*float64(failed+ok) {
+		return Degraded
+	}
+	return Healthy
+}
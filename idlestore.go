@@ -0,0 +1,212 @@
+package thriftpool
+
+import (
+	"container/list"
+	"sort"
+	"sync"
+	"time"
+)
+
+// IdleEntry is an idle connection held by an IdleStore.
+type IdleEntry struct {
+	Client *IdleClient
+	// Since is when the connection became idle.
+	Since time.Time
+
+	// suspect connections were returned after a failed call and are evicted
+	// first on the next sweep
+	suspect bool
+	addr    string
+
+	ele     *list.Element
+	addrEle *list.Element
+	cold    bool
+	// index and seq place the entry in a priorityStore
+	index int
+	seq   uint64
+	// pinned entries are held by a RefreshIdle snapshot and are not
+	// recycled, so the snapshot can't mistake a later Put for them
+	pinned bool
+}
+
+// entries recycles IdleEntries for pools built WithEntryReuse.
+var entries = sync.Pool{
+	New: func() interface{} { return new(IdleEntry) },
+}
+
+func (p *ThriftPool) newIdleEntry(client *IdleClient, since time.Time, suspect bool) *IdleEntry {
+	if !p.opts.reuseEntries {
+		return &IdleEntry{Client: client, Since: since, suspect: suspect}
+	}
+	e := entries.Get().(*IdleEntry)
+	e.Client, e.Since, e.suspect = client, since, suspect
+	return e
+}
+
+// freeIdleEntry recycles e once it has left the store and nothing reads it.
+func (p *ThriftPool) freeIdleEntry(e *IdleEntry) {
+	if !p.opts.reuseEntries || e.pinned {
+		return
+	}
+	*e = IdleEntry{}
+	entries.Put(e)
+}
+
+// IdleStore holds a pool's idle connections and decides which one Get hands
+// out next. The pool calls it with its lock held, so implementations need no
+// locking of their own, as long as Len and Each only read: read-only
+// accessors hold the lock shared and may call them concurrently.
+type IdleStore interface {
+	Len() int
+	Put(e *IdleEntry)
+	// Take removes and returns the first entry, in the store's borrow
+	// order, that pred accepts. A nil pred accepts any entry. It returns
+	// nil if there is no such entry.
+	Take(pred func(*IdleEntry) bool) *IdleEntry
+	// Remove removes e and reports whether the store held it.
+	Remove(e *IdleEntry) bool
+	// Evict removes and returns the entries idle since before deadline,
+	// oldest first, leaving at least keep entries in the store.
+	Evict(deadline time.Time, keep int) []*IdleEntry
+	// Each calls fn on the entries in borrow order until fn returns false.
+	Each(fn func(*IdleEntry) bool)
+}
+
+// addrStore is implemented by stores that index entries by remote address.
+type addrStore interface {
+	takeAddr(addr string) *IdleEntry
+	countByAddr() map[string]uint32
+}
+
+// NewFIFOStore returns the default store, which hands out the connection that
+// has been idle longest.
+func NewFIFOStore() IdleStore {
+	return newListStore(false)
+}
+
+// NewLIFOStore returns a store that hands out the most recently returned
+// connection, letting surplus connections age out at quiet times.
+func NewLIFOStore() IdleStore {
+	return newListStore(true)
+}
+
+type listStore struct {
+	lifo   bool
+	l      list.List
+	byAddr map[string]*list.List
+}
+
+func newListStore(lifo bool) *listStore {
+	return &listStore{
+		lifo:   lifo,
+		byAddr: make(map[string]*list.List),
+	}
+}
+
+func (s *listStore) Len() int {
+	return s.l.Len()
+}
+
+func (s *listStore) Put(e *IdleEntry) {
+	e.ele = s.l.PushBack(e)
+	if e.addr != "" {
+		l := s.byAddr[e.addr]
+		if l == nil {
+			l = list.New()
+			s.byAddr[e.addr] = l
+		}
+		e.addrEle = l.PushBack(e)
+	}
+}
+
+func (s *listStore) first(l *list.List) *list.Element {
+	if s.lifo {
+		return l.Back()
+	}
+	return l.Front()
+}
+
+func (s *listStore) next(ele *list.Element) *list.Element {
+	if s.lifo {
+		return ele.Prev()
+	}
+	return ele.Next()
+}
+
+func (s *listStore) Take(pred func(*IdleEntry) bool) *IdleEntry {
+	for ele := s.first(&s.l); ele != nil; ele = s.next(ele) {
+		e := ele.Value.(*IdleEntry)
+		if pred == nil || pred(e) {
+			s.Remove(e)
+			return e
+		}
+	}
+	return nil
+}
+
+func (s *listStore) Remove(e *IdleEntry) bool {
+	if e.ele == nil {
+		return false
+	}
+	s.l.Remove(e.ele)
+	e.ele = nil
+	if e.addrEle != nil {
+		l := s.byAddr[e.addr]
+		l.Remove(e.addrEle)
+		if l.Len() == 0 {
+			delete(s.byAddr, e.addr)
+		}
+		e.addrEle = nil
+	}
+	return true
+}
+
+func (s *listStore) Evict(deadline time.Time, keep int) []*IdleEntry {
+	// entries are pushed at the back, but handing connections back out of
+	// order means the list isn't sorted by Since
+	var expired []*IdleEntry
+	for ele := s.l.Front(); ele != nil; ele = ele.Next() {
+		if e := ele.Value.(*IdleEntry); !e.Since.After(deadline) {
+			expired = append(expired, e)
+		}
+	}
+	sort.SliceStable(expired, func(i, j int) bool {
+		return expired[i].Since.Before(expired[j].Since)
+	})
+	if n := s.l.Len() - keep; n < len(expired) {
+		if n < 0 {
+			n = 0
+		}
+		expired = expired[:n]
+	}
+	for _, e := range expired {
+		s.Remove(e)
+	}
+	return expired
+}
+
+func (s *listStore) Each(fn func(*IdleEntry) bool) {
+	for ele := s.first(&s.l); ele != nil; ele = s.next(ele) {
+		if !fn(ele.Value.(*IdleEntry)) {
+			return
+		}
+	}
+}
+
+func (s *listStore) takeAddr(addr string) *IdleEntry {
+	l := s.byAddr[addr]
+	if l == nil {
+		return nil
+	}
+	e := s.first(l).Value.(*IdleEntry)
+	s.Remove(e)
+	return e
+}
+
+func (s *listStore) countByAddr() map[string]uint32 {
+	byAddr := make(map[string]uint32, len(s.byAddr))
+	for addr, l := range s.byAddr {
+		byAddr[addr] = uint32(l.Len())
+	}
+	return byAddr
+}
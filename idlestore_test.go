@@ -0,0 +1,105 @@
+package thriftpool_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/lehaisonmath6/thriftpool"
+	"github.com/lehaisonmath6/thriftpool/thriftpooltest"
+)
+
+func TestRefreshIdleSkipsReborrowed(t *testing.T) {
+	for _, reuse := range []bool{false, true} {
+		d := &thriftpooltest.Dialer{}
+		opts := []thriftpool.Option{thriftpool.WithRefreshInterval(100 * time.Millisecond)}
+		if reuse {
+			opts = append(opts, thriftpool.WithEntryReuse())
+		}
+		p := thriftpool.NewThriftPool("127.0.0.1", "9090", 3, 1, 10, d.Dial, nil, opts...)
+		a, _ := p.Get()
+		b, _ := p.Get()
+		p.Put(a)
+		p.Put(b)
+
+		done := make(chan int)
+		go func() {
+			n, err := p.RefreshIdle(context.Background())
+			if err != nil {
+				t.Error(err)
+			}
+			done <- n
+		}()
+		// once a is replaced, borrow and return b before its turn comes
+		for d.Dials() < 3 {
+			time.Sleep(time.Millisecond)
+		}
+		c, err := p.Get()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c != b {
+			t.Fatalf("reuse=%v: Get during refresh did not hand out the oldest connection", reuse)
+		}
+		p.Put(c)
+
+		if n := <-done; n != 1 {
+			t.Errorf("reuse=%v: refreshed %d connections, want 1", reuse, n)
+		}
+		if !b.Check() {
+			t.Errorf("reuse=%v: connection borrowed during the refresh was closed", reuse)
+		}
+		p.Release()
+	}
+}
+
+func BenchmarkGetPut(b *testing.B) {
+	for _, bc := range []struct {
+		name string
+		opts []thriftpool.Option
+	}{
+		{"alloc", nil},
+		{"reuse", []thriftpool.Option{thriftpool.WithEntryReuse()}},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			p := thriftpool.NewThriftPool("127.0.0.1", "9090", 1, 1, 10,
+				thriftpooltest.FakeDial, thriftpooltest.FakeClose, bc.opts...)
+			defer p.Release()
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				c, err := p.Get()
+				if err != nil {
+					b.Fatal(err)
+				}
+				p.Put(c)
+			}
+		})
+	}
+}
+
+func TestEvictOldestFirst(t *testing.T) {
+	base := time.Unix(1000, 0)
+	s := thriftpool.NewFIFOStore()
+	// pushed out of Since order, as connections handed back out of order are
+	for _, sec := range []int{3, 1, 4, 2, 9} {
+		s.Put(&thriftpool.IdleEntry{Since: base.Add(time.Duration(sec) * time.Second)})
+	}
+
+	evicted := s.Evict(base.Add(5*time.Second), 2)
+	if len(evicted) != 3 {
+		t.Fatalf("evicted %d entries, want 3", len(evicted))
+	}
+	for i, want := range []int{1, 2, 3} {
+		if got := evicted[i].Since.Sub(base); got != time.Duration(want)*time.Second {
+			t.Errorf("evicted[%d] idle since +%v, want +%ds", i, got, want)
+		}
+	}
+	var left []time.Duration
+	s.Each(func(e *thriftpool.IdleEntry) bool {
+		left = append(left, e.Since.Sub(base))
+		return true
+	})
+	if len(left) != 2 || left[0] != 4*time.Second || left[1] != 9*time.Second {
+		t.Errorf("left %v, want the two newest, [4s 9s]", left)
+	}
+}
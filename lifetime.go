@@ -0,0 +1,214 @@
+package thriftpool
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// LIFETIMESAMPLES is how many recent lifetimes are kept per CloseReason for
+// the percentiles in LifetimeSummary.
+const LIFETIMESAMPLES = 512
+
+// CloseReason is why the pool closed a connection.
+type CloseReason int
+
+const (
+	// CloseIdle connections sat idle past idleTimeout, or were surplus to
+	// maxIdle or the idle LRU.
+	CloseIdle CloseReason = iota
+	// CloseLifetime connections outlived maxLifetime.
+	CloseLifetime
+	// CloseReuse connections reached maxUsage.
+	CloseReuse
+	// CloseInvalid connections failed Check or validation, or were handed
+	// to CloseErrConn.
+	CloseInvalid
+	numCloseReasons
+)
+
+func (r CloseReason) String() string {
+	switch r {
+	case CloseIdle:
+		return "idle"
+	case CloseLifetime:
+		return "lifetime"
+	case CloseReuse:
+		return "reuse"
+	case CloseInvalid:
+		return "invalid"
+	}
+	return "unknown"
+}
+
+// MarshalText makes CloseReason keys readable in encoded Stats.
+func (r CloseReason) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+// LifetimeSummary describes how long connections closed for one reason had
+// been open. Avg covers every such close, the percentiles the most recent
+// LIFETIMESAMPLES.
+type LifetimeSummary struct {
+	Count uint64
+	Avg   time.Duration
+	P50   time.Duration
+	P95   time.Duration
+}
+
+type lifetimes struct {
+	mu      sync.Mutex
+	count   [numCloseReasons]uint64
+	total   [numCloseReasons]time.Duration
+	samples [numCloseReasons][]time.Duration
+	next    [numCloseReasons]int
+}
+
+func (l *lifetimes) record(r CloseReason, d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.count[r]++
+	l.total[r] += d
+	if len(l.samples[r]) < LIFETIMESAMPLES {
+		l.samples[r] = append(l.samples[r], d)
+		return
+	}
+	l.samples[r][l.next[r]] = d
+	l.next[r] = (l.next[r] + 1) % LIFETIMESAMPLES
+}
+
+func (l *lifetimes) summary() map[CloseReason]LifetimeSummary {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	m := make(map[CloseReason]LifetimeSummary)
+	for r := CloseReason(0); r < numCloseReasons; r++ {
+		if l.count[r] == 0 {
+			continue
+		}
+		sorted := append([]time.Duration(nil), l.samples[r]...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		m[r] = LifetimeSummary{
+			Count: l.count[r],
+			Avg:   l.total[r] / time.Duration(l.count[r]),
+			P50:   sorted[len(sorted)*50/100],
+			P95:   sorted[len(sorted)*95/100],
+		}
+	}
+	return m
+}
+
+// mergeLifetimes summarizes the lifetimes of several pools together, pooling
+// their samples so the percentiles cover all of them.
+func mergeLifetimes(pools []*ThriftPool) map[CloseReason]LifetimeSummary {
+	var all lifetimes
+	for _, p := range pools {
+		l := &p.stats.lifetimes
+		l.mu.Lock()
+		for r := range l.count {
+			all.count[r] += l.count[r]
+			all.total[r] += l.total[r]
+			all.samples[r] = append(all.samples[r], l.samples[r]...)
+		}
+		l.mu.Unlock()
+	}
+	return all.summary()
+}
+
+// CloseCauses counts the connections the pool closed, by cause.
+type CloseCauses struct {
+	// Reset connections failed Check, typically reset by the server.
+	Reset uint64
+	// DialFailed connections were dialed but failed OnConnect or priming.
+	DialFailed  uint64
+	Validation  uint64
+	IdleTimeout uint64
+	// Surplus connections were beyond MaxIdle, MaxConn or the idle LRU.
+	Surplus  uint64
+	Lifetime uint64
+	MaxUsage uint64
+	// CallerError connections were handed to CloseErrConn or returned
+	// with PutResult false.
+	CallerError uint64
+	// Revoked connections were borrowed past MaxBorrowTime.
+	Revoked uint64
+	// Retired connections were overflow, pointed at a stale address, or
+	// closed by CloseWhere, DrainFraction, RefreshIdle or MigrateFrom.
+	Retired  uint64
+	Shutdown uint64
+}
+
+func (c *CloseCauses) add(o CloseCauses) {
+	c.Reset += o.Reset
+	c.DialFailed += o.DialFailed
+	c.Validation += o.Validation
+	c.IdleTimeout += o.IdleTimeout
+	c.Surplus += o.Surplus
+	c.Lifetime += o.Lifetime
+	c.MaxUsage += o.MaxUsage
+	c.CallerError += o.CallerError
+	c.Revoked += o.Revoked
+	c.Retired += o.Retired
+	c.Shutdown += o.Shutdown
+}
+
+type closeCause int
+
+const (
+	causeReset closeCause = iota
+	causeDialFailed
+	causeValidation
+	causeIdleTimeout
+	causeSurplus
+	causeLifetime
+	causeMaxUsage
+	causeCallerError
+	causeRevoked
+	causeRetired
+	causeShutdown
+	numCloseCauses
+)
+
+// causeReasons maps each cause to the CloseReason its lifetime is summarized
+// under, numCloseReasons for none.
+var causeReasons = [numCloseCauses]CloseReason{
+	causeReset:       CloseInvalid,
+	causeDialFailed:  numCloseReasons,
+	causeValidation:  CloseInvalid,
+	causeIdleTimeout: CloseIdle,
+	causeSurplus:     CloseIdle,
+	causeLifetime:    CloseLifetime,
+	causeMaxUsage:    CloseReuse,
+	causeCallerError: CloseInvalid,
+	causeRevoked:     numCloseReasons,
+	causeRetired:     numCloseReasons,
+	causeShutdown:    numCloseReasons,
+}
+
+// closing counts client as about to be closed for cause and records how long
+// it lived.
+func (p *ThriftPool) closing(client *IdleClient, cause closeCause) {
+	atomic.AddUint64(&p.stats.causes[cause], 1)
+	r := causeReasons[cause]
+	if r == numCloseReasons || client == nil || client.createdAt.IsZero() {
+		return
+	}
+	p.stats.lifetimes.record(r, p.now().Sub(client.createdAt))
+}
+
+func (c *counters) closeCauses() CloseCauses {
+	n := func(cause closeCause) uint64 { return atomic.LoadUint64(&c.causes[cause]) }
+	return CloseCauses{
+		Reset:       n(causeReset),
+		DialFailed:  n(causeDialFailed),
+		Validation:  n(causeValidation),
+		IdleTimeout: n(causeIdleTimeout),
+		Surplus:     n(causeSurplus),
+		Lifetime:    n(causeLifetime),
+		MaxUsage:    n(causeMaxUsage),
+		CallerError: n(causeCallerError),
+		Revoked:     n(causeRevoked),
+		Retired:     n(causeRetired),
+		Shutdown:    n(causeShutdown),
+	}
+}
@@ -0,0 +1,72 @@
+package thriftpool
+
+import "net"
+
+// MigrateFrom adopts old's idle connections into p and then releases old, so
+// that a pool rebuilt on config reload doesn't redial everything. A
+// connection is adopted if it was dialed to one of p's addresses, or the
+// address p's resolver returns now, passes Check, is within p's MaxLifetime,
+// and p has a free slot for it; the rest are closed with old. Connections
+// borrowed from old are closed when they are returned to it. It returns the
+// number of connections adopted.
+func (p *ThriftPool) MigrateFrom(old *ThriftPool) (int, error) {
+	if old == nil || old == p {
+		return 0, nil
+	}
+	targets := make(map[string]bool, len(p.addrs))
+	if p.opts.resolver != nil {
+		ip, port, err := p.callResolver()
+		if err != nil {
+			return 0, err
+		}
+		targets[net.JoinHostPort(ip, port)] = true
+	} else {
+		for _, addr := range p.addrs {
+			targets[addr] = true
+		}
+	}
+
+	old.lock.Lock()
+	var moving []*IdleClient
+	var staying []*IdleEntry
+	for e := old.idle.Take(nil); e != nil; e = old.idle.Take(nil) {
+		if targets[e.Client.target] {
+			moving = append(moving, e.Client)
+			old.releaseSlotLocked(false)
+			continue
+		}
+		staying = append(staying, e)
+	}
+	for _, e := range staying {
+		old.idle.Put(e)
+	}
+	old.unlock()
+	for _, c := range moving {
+		untrack(c)
+	}
+
+	p.lock.Lock()
+	now := p.now()
+	adopted := 0
+	var rejected []*IdleClient
+	for _, c := range moving {
+		if p.closed || !c.Check() || p.lifetimeExpiredLocked(c, now) ||
+			p.fullLocked() || !p.takeSlotLocked() {
+			rejected = append(rejected, c)
+			continue
+		}
+		c.home = p
+		c.clock = p.now
+		c.overflow = false
+		p.trackLocked(c)
+		p.pushIdle(c)
+		adopted++
+	}
+	p.unlock()
+
+	for _, c := range rejected {
+		old.closing(c, causeRetired)
+		old.closeClient(c)
+	}
+	return adopted, old.Release()
+}
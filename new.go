@@ -0,0 +1,91 @@
+package thriftpool
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+)
+
+// WithDial sets the dial function for New.
+func WithDial(dial ThriftDial) Option {
+	return func(o *options) {
+		o.dial = dial
+	}
+}
+
+// WithClose sets the close function for New. It defaults to closing the
+// socket.
+func WithClose(closeFunc ThriftClientClose) Option {
+	return func(o *options) {
+		o.closeFunc = closeFunc
+	}
+}
+
+// WithMaxConn sets the connection limit for New.
+func WithMaxConn(n uint32) Option {
+	return func(o *options) {
+		o.maxConn = n
+	}
+}
+
+// WithConnTimeout sets the socket timeout for New.
+func WithConnTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.connTimeout = d
+	}
+}
+
+// WithIdleTimeout sets how long a connection may stay idle for New.
+func WithIdleTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.idleTimeout = d
+	}
+}
+
+// New builds a pool like NewThriftPool, taking everything as options, and
+// reports an ErrInvalidConfig error instead of returning a pool that would
+// misbehave. The first address given WithAddrs is the pool's ip:port, and the
+// others are dialed round robin with it. New needs WithDial, WithAddrs or
+// WithResolver, and WithMaxConn unless the pool is WithUnlimited. Nothing is
+// allocated or started for a config it rejects.
+func New(opts ...Option) (*ThriftPool, error) {
+	o := collectOptions(opts)
+	if err := o.check(); err != nil {
+		return nil, err
+	}
+	return fromOptions(o), nil
+}
+
+// check validates o before New allocates anything for it.
+func (o options) check() error {
+	switch {
+	case o.dial == nil:
+		return fmt.Errorf("%w: no dial function", ErrInvalidConfig)
+	case o.maxConn == 0 && !o.unlimited:
+		return fmt.Errorf("%w: maxConn must be positive", ErrInvalidConfig)
+	case len(o.addrs) == 0 && o.resolver == nil:
+		return fmt.Errorf("%w: neither an address nor a resolver", ErrInvalidConfig)
+	}
+	for _, addr := range o.addrs {
+		if err := checkAddr(addr); err != nil {
+			return err
+		}
+	}
+	o.setDefaults()
+	return o.config(o.maxConn, o.connTimeout, o.idleTimeout).validate()
+}
+
+func checkAddr(addr string) error {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+	}
+	if host == "" {
+		return fmt.Errorf("%w: address %q has no host", ErrInvalidConfig, addr)
+	}
+	if n, err := strconv.Atoi(port); err != nil || n <= 0 || n > 65535 {
+		return fmt.Errorf("%w: address %q has invalid port", ErrInvalidConfig, addr)
+	}
+	return nil
+}
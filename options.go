@@ -0,0 +1,760 @@
+package thriftpool
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+type Option func(*options)
+
+type options struct {
+	// the pool's basics, set by New's options only
+	dial        ThriftDial
+	closeFunc   ThriftClientClose
+	maxConn     uint32
+	connTimeout time.Duration
+	idleTimeout time.Duration
+
+	addrs            []string
+	affinityFallback bool
+
+	logger             Logger
+	leakDetection      bool
+	maxBorrowTime      time.Duration
+	forceCloseBorrowed bool
+
+	validate          func(*IdleClient) error
+	validateAfterIdle time.Duration
+
+	overflow OverflowPolicy
+
+	onEmpty func()
+
+	minIdle       uint32
+	maxIdle       uint32
+	checkInterval time.Duration
+
+	sampleFraction float64
+
+	resolver    func() (ip, port string, err error)
+	rejectStale bool
+	maxLifetime time.Duration
+
+	baseCtx context.Context
+
+	breakerThreshold uint32
+	breakerCooldown  time.Duration
+
+	unlimited bool
+
+	eventBuffer int
+
+	closeErrBuffer int
+
+	releaseTimeout     time.Duration
+	releaseConcurrency int
+
+	newIdleStore func() IdleStore
+
+	now func() time.Time
+
+	asyncClose bool
+
+	reuseEntries bool
+
+	maxWaiters int
+
+	maxConnErrors uint32
+
+	serveIdleWhenOpen bool
+
+	suggestHeadroom float64
+
+	perAddrMaxConn uint32
+
+	onResize func(old, new uint32)
+
+	releaseOrder ReleaseOrder
+
+	validateOnPut      bool
+	putValidateWorkers int
+	putValidateQueue   int
+
+	staleReuseAfter time.Duration
+	onStaleReuse    func(c *IdleClient, idleFor time.Duration)
+
+	randSeed int64
+	seeded   bool
+
+	refreshInterval time.Duration
+
+	dialCoalesce uint32
+
+	onBorrow func(*IdleClient) (*IdleClient, error)
+	onReturn func(*IdleClient) (*IdleClient, error)
+
+	idleFromActivity bool
+
+	classifyError func(err error) (poison bool)
+
+	statsInterval time.Duration
+
+	maxConcurrentDials int
+
+	healthErrorRate float64
+	healthWindow    time.Duration
+
+	dialRate  float64
+	dialBurst int
+
+	onConnect func(*IdleClient) error
+
+	maxUsage int64
+
+	prime func(*IdleClient) error
+
+	idleLRU int
+
+	resolve       func(ctx context.Context, host, port string) ([]net.Addr, error)
+	happyEyeballs time.Duration
+
+	dialTimeout time.Duration
+
+	deadlinePropagation bool
+
+	dialRetry dialRetry
+
+	connState func(*IdleClient, ConnState)
+
+	reapDead bool
+
+	tenantKey     func(context.Context) string
+	tenantPerPool bool
+
+	// limit is shared by the shards of a ShardedPool.
+	limit *slotLimit
+}
+
+func collectOptions(opts []Option) options {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// setDefaults fills in the settings left unset.
+func (o *options) setDefaults() {
+	if o.checkInterval <= 0 {
+		o.checkInterval = CHECKINTERVAL * time.Second
+	}
+	if o.releaseTimeout <= 0 {
+		o.releaseTimeout = RELEASETIMEOUT * time.Second
+	}
+	if o.releaseConcurrency <= 0 {
+		o.releaseConcurrency = RELEASECONCURRENCY
+	}
+	if o.refreshInterval <= 0 {
+		o.refreshInterval = REFRESHINTERVAL * time.Millisecond
+	}
+	if o.healthWindow <= 0 {
+		o.healthWindow = HEALTHWINDOW * time.Second
+	}
+	if o.suggestHeadroom <= 0 {
+		o.suggestHeadroom = SUGGESTHEADROOM
+	}
+	if o.healthErrorRate <= 0 {
+		o.healthErrorRate = HEALTHERRORRATE
+	}
+}
+
+// WithAddrs adds backend addresses ("host:port") that new connections are
+// dialed to in round-robin order together with the pool's ip:port.
+func WithAddrs(addrs ...string) Option {
+	return func(o *options) {
+		o.addrs = append(o.addrs, addrs...)
+	}
+}
+
+// WithAffinityFallback makes GetForAddr fall back to any connection when the
+// preferred address has no idle connection and can't be dialed.
+func WithAffinityFallback() Option {
+	return func(o *options) {
+		o.affinityFallback = true
+	}
+}
+
+// WithLogger sets the logger used to report pool diagnostics.
+func WithLogger(logger Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// WithLeakDetection records the stack of every Get so that connections held
+// past the max borrow time can be traced back to their caller.
+func WithLeakDetection() Option {
+	return func(o *options) {
+		o.leakDetection = true
+	}
+}
+
+// WithMaxBorrowTime reports connections borrowed for longer than d on each
+// reaper tick and, if forceClose is set, closes them and frees their slot.
+func WithMaxBorrowTime(d time.Duration, forceClose bool) Option {
+	return func(o *options) {
+		o.maxBorrowTime = d
+		o.forceCloseBorrowed = forceClose
+	}
+}
+
+// WithValidate runs fn on an idle connection before Get hands it out. A
+// connection failing validation is closed and Get moves on to the next one.
+func WithValidate(fn func(*IdleClient) error) Option {
+	return func(o *options) {
+		o.validate = fn
+	}
+}
+
+// WithValidateAfterIdle only validates connections that have been idle for at
+// least d, so recently returned connections skip the check. Zero validates
+// every borrow.
+func WithValidateAfterIdle(d time.Duration) Option {
+	return func(o *options) {
+		o.validateAfterIdle = d
+	}
+}
+
+// OverflowPolicy decides what Get does when maxConn connections are open and
+// none is idle.
+type OverflowPolicy struct {
+	burst uint32
+	block bool
+}
+
+// Reject makes Get fail with ErrOverMax. It is the default.
+var Reject = OverflowPolicy{}
+
+// Block makes Get wait until a connection is returned or a slot frees up,
+// for as long as its context allows.
+var Block = OverflowPolicy{block: true}
+
+// Overflow lets Get open up to n temporary connections beyond maxConn. They
+// are never pooled: Put closes them and frees their slot.
+func Overflow(n uint32) OverflowPolicy {
+	return OverflowPolicy{burst: n}
+}
+
+func WithOverflowPolicy(policy OverflowPolicy) Option {
+	return func(o *options) {
+		o.overflow = policy
+	}
+}
+
+// WithOnEmpty calls fn each time the number of open connections drops to
+// zero, e.g. once the last borrowed connection comes back after Release.
+// Dials failing while the pool holds no connection don't count as draining
+// it again. fn runs without the pool lock held.
+func WithOnEmpty(fn func()) Option {
+	return func(o *options) {
+		o.onEmpty = fn
+	}
+}
+
+// WithMinIdle keeps at least n idle connections: the reaper won't evict below
+// n and dials new connections to get back up to it.
+func WithMinIdle(n uint32) Option {
+	return func(o *options) {
+		o.minIdle = n
+	}
+}
+
+// WithMaxIdle closes returned connections instead of pooling them once n are
+// idle. Zero means no limit.
+func WithMaxIdle(n uint32) Option {
+	return func(o *options) {
+		o.maxIdle = n
+	}
+}
+
+// WithCheckInterval sets how often the reaper runs. It defaults to
+// CHECKINTERVAL seconds.
+func WithCheckInterval(d time.Duration) Option {
+	return func(o *options) {
+		o.checkInterval = d
+	}
+}
+
+// WithIdleSampling validates a random fraction of the idle connections on
+// every reaper tick, evicting those that fail. It needs WithValidate.
+func WithIdleSampling(fraction float64) Option {
+	return func(o *options) {
+		o.sampleFraction = fraction
+	}
+}
+
+// WithResolver makes Get ask fn for the address of every new connection
+// instead of using the configured ip and port. Pooled connections keep the
+// address they were dialed with until they are reaped, see WithMaxLifetime.
+func WithResolver(fn func() (ip, port string, err error)) Option {
+	return func(o *options) {
+		o.resolver = fn
+	}
+}
+
+// WithRejectStaleAddr makes Put close, instead of pooling, a connection
+// dialed to another address than the one the WithResolver function last
+// returned, so traffic moves to a new backend address as connections are
+// returned. The resolver is asked on every dial and reaper tick, not by Put;
+// a resolver error keeps the previous answer.
+func WithRejectStaleAddr() Option {
+	return func(o *options) {
+		o.rejectStale = true
+	}
+}
+
+// WithMaxLifetime closes connections once they have been open for d, whether
+// they are idle or returned by Put. Zero means no limit.
+func WithMaxLifetime(d time.Duration) Option {
+	return func(o *options) {
+		o.maxLifetime = d
+	}
+}
+
+// WithBaseContext sets the context Get uses. Cancelling it aborts every Get
+// and GetContext in progress, which gives a single switch for shutdown.
+func WithBaseContext(ctx context.Context) Option {
+	return func(o *options) {
+		o.baseCtx = ctx
+	}
+}
+
+// WithCircuitBreaker stops dialing for cooldown after threshold consecutive
+// dial failures; Gets that need a new connection meanwhile fail with
+// ErrCircuitOpen. Once cooldown has passed a dial is let through, and its
+// outcome closes or re-opens the breaker.
+func WithCircuitBreaker(threshold uint32, cooldown time.Duration) Option {
+	return func(o *options) {
+		o.breakerThreshold = threshold
+		o.breakerCooldown = cooldown
+	}
+}
+
+// WithServeIdleWhenOpen keeps the pool serving from its idle connections for
+// as long as they last while the circuit is open or half-open. The breaker
+// only ever blocks dials, so Get reuses idle connections regardless; this
+// also stops the idle timeout and MaxLifetime from closing them meanwhile,
+// since they couldn't be replaced. Connections failing Check or validation
+// are still closed.
+func WithServeIdleWhenOpen() Option {
+	return func(o *options) {
+		o.serveIdleWhenOpen = true
+	}
+}
+
+// WithUnlimited removes the connection cap: maxConn is ignored and Get never
+// fails with ErrOverMax. Connections are still counted for Stats, and idle
+// ones are reaped as usual.
+func WithUnlimited() Option {
+	return func(o *options) {
+		o.unlimited = true
+	}
+}
+
+// WithEvents publishes pool events on the channel returned by Events, which
+// buffers up to buffer of them.
+func WithEvents(buffer int) Option {
+	return func(o *options) {
+		o.eventBuffer = buffer
+	}
+}
+
+// WithReleaseTimeout bounds how long Release waits for idle connections to
+// close. It defaults to RELEASETIMEOUT seconds.
+func WithReleaseTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.releaseTimeout = d
+	}
+}
+
+// WithReleaseConcurrency sets how many connections Release closes at once. It
+// defaults to RELEASECONCURRENCY.
+func WithReleaseConcurrency(n int) Option {
+	return func(o *options) {
+		o.releaseConcurrency = n
+	}
+}
+
+// WithIdleStore sets the constructor of the store holding idle connections,
+// e.g. NewLIFOStore. It is called once per pool, including clones. The
+// default is NewFIFOStore.
+func WithIdleStore(newStore func() IdleStore) Option {
+	return func(o *options) {
+		o.newIdleStore = newStore
+	}
+}
+
+// WithClock replaces time.Now for the pool's idle, lifetime and borrow
+// bookkeeping, so tests can drive eviction deterministically.
+func WithClock(now func() time.Time) Option {
+	return func(o *options) {
+		o.now = now
+	}
+}
+
+// WithAsyncClose hands connections that Get and Put discard to a background
+// worker instead of closing them on the caller's goroutine. Up to
+// ASYNCCLOSEQUEUE closes can be pending; beyond that, and once the pool is
+// released, closes happen inline. Release waits for pending closes.
+func WithAsyncClose() Option {
+	return func(o *options) {
+		o.asyncClose = true
+	}
+}
+
+// WithTieredIdle keeps idle connections in a NewTieredStore with the given
+// tier sizes.
+func WithTieredIdle(hotSize, coldSize int) Option {
+	return WithIdleStore(func() IdleStore {
+		return NewTieredStore(hotSize, coldSize)
+	})
+}
+
+// WithRefreshInterval sets the pause between reconnects in RefreshIdle. It
+// defaults to REFRESHINTERVAL milliseconds.
+func WithRefreshInterval(d time.Duration) Option {
+	return func(o *options) {
+		o.refreshInterval = d
+	}
+}
+
+// WithDialCoalescing caps the number of dials Gets start for new connections
+// at n. A Get that finds nothing idle while n such dials are in flight waits,
+// bounded by its context, for one of them to finish or for a connection to be
+// returned, instead of dialing itself. Dials still take a slot under maxConn
+// when they start, so a full pool is handled by the overflow policy as before;
+// a waiting Get holds no slot. Zero, the default, disables coalescing.
+func WithDialCoalescing(n uint32) Option {
+	return func(o *options) {
+		o.dialCoalesce = n
+	}
+}
+
+// WithOnBorrow calls fn on every connection Get is about to hand out, and
+// hands out what fn returns instead, e.g. the connection with its Client
+// wrapped in instrumentation. If fn fails, the connection is closed and Get
+// tries the next idle one; a freshly dialed connection fails the Get with
+// fn's error.
+func WithOnBorrow(fn func(*IdleClient) (*IdleClient, error)) Option {
+	return func(o *options) {
+		o.onBorrow = fn
+	}
+}
+
+// WithOnReturn calls fn on every connection passed to Put, PutResult and
+// CloseErrConn before the pool takes it back. If WithOnBorrow hands out a
+// different *IdleClient, fn must map it back to the one it was given. If fn
+// fails, the connection is closed and Put returns fn's error.
+func WithOnReturn(fn func(*IdleClient) (*IdleClient, error)) Option {
+	return func(o *options) {
+		o.onReturn = fn
+	}
+}
+
+// WithIdleFromActivity measures idle time, for the idle timeout and
+// WithValidateAfterIdle, from a connection's last activity instead of from
+// when it was returned. Activity is the dial, each borrow, and each call to
+// IdleClient.Touch, so a connection that sat borrowed but unused comes back
+// already aged, as it is on a server that closes quiet connections.
+func WithIdleFromActivity() Option {
+	return func(o *options) {
+		o.idleFromActivity = true
+	}
+}
+
+// WithErrorClassifier sets the function PutErr uses to decide whether an RPC
+// error leaves the connection unusable. It defaults to DefaultClassifyError.
+func WithErrorClassifier(fn func(err error) (poison bool)) Option {
+	return func(o *options) {
+		o.classifyError = fn
+	}
+}
+
+// WithStatsLogging logs a one-line summary of Stats to the pool's logger
+// every interval until the pool is released. It does nothing without
+// WithLogger.
+func WithStatsLogging(interval time.Duration) Option {
+	return func(o *options) {
+		o.statsInterval = interval
+	}
+}
+
+// WithMaxConcurrentDials allows at most n dials to be in progress at once,
+// across Gets, GetForAddr and the idle fill. Callers beyond the limit wait
+// for a dial to finish, bounded by their context. The connections they are
+// about to dial already count against maxConn while they wait.
+func WithMaxConcurrentDials(n int) Option {
+	return func(o *options) {
+		o.maxConcurrentDials = n
+	}
+}
+
+// WithOnConnect runs fn on every newly dialed connection before it is handed
+// out or pooled, e.g. to perform a handshake or auth RPC. If fn fails, the
+// connection is closed and the dial fails with a DialError wrapping fn's
+// error.
+func WithOnConnect(fn func(*IdleClient) error) Option {
+	return func(o *options) {
+		o.onConnect = fn
+	}
+}
+
+// WithMaxUsage closes connections returned with at least n usage recorded by
+// IdleClient.AddUsage instead of pooling them. Zero, the default, means no
+// limit.
+func WithMaxUsage(n int64) Option {
+	return func(o *options) {
+		o.maxUsage = n
+	}
+}
+
+// WithPrime runs fn once on every newly dialed connection, after the
+// WithOnConnect handshake, to prepare it for traffic, e.g. with a warmup RPC
+// that fills server-side caches. Failures count as dial failures, as with
+// WithOnConnect. The three hooks differ in when they run: OnConnect and Prime
+// once per connection, to establish and then warm it, and WithValidate on
+// borrows of idle connections, to check that they still work.
+func WithPrime(fn func(*IdleClient) error) Option {
+	return func(o *options) {
+		o.prime = fn
+	}
+}
+
+// WithIdleLRU caps the idle connections at n like WithMaxIdle, but instead
+// of closing a connection returned to a full idle store, Put closes the one
+// that has been idle longest to make room, so the most recently active
+// connections stay pooled.
+func WithIdleLRU(n int) Option {
+	return func(o *options) {
+		o.idleLRU = n
+	}
+}
+
+// WithResolve calls fn before every dial to turn the host and port chosen
+// for it, by round robin or WithResolver, into addresses, which are then
+// dialed in order until one succeeds. This lets callers cache DNS or pick
+// address families themselves. Without it the host is passed to the dial
+// function as is.
+func WithResolve(fn func(ctx context.Context, host, port string) ([]net.Addr, error)) Option {
+	return func(o *options) {
+		o.resolve = fn
+	}
+}
+
+// WithDialTimeout bounds dialing a new connection, including the WithOnConnect
+// and WithPrime hooks, by d instead of connTimeout, which then only applies to
+// reads and writes on the connection.
+func WithDialTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.dialTimeout = d
+	}
+}
+
+// WithCloseErrors makes CloseErrors deliver the errors returned by the close
+// function, through a channel buffered for buffer errors.
+func WithCloseErrors(buffer int) Option {
+	return func(o *options) {
+		o.closeErrBuffer = buffer
+	}
+}
+
+// WithDeadlinePropagation sets the socket timeout of a connection borrowed
+// with GetContext to the time left until the context's deadline, so the RPCs
+// made on it honor the deadline. Put restores connTimeout. If the deadline
+// has passed by the time a connection is ready, GetContext returns it to the
+// pool and fails with context.DeadlineExceeded.
+func WithDeadlinePropagation() Option {
+	return func(o *options) {
+		o.deadlinePropagation = true
+	}
+}
+
+// WithDialRetry retries a Get's failed dial up to retries times, backoff
+// apart. With holdSlot the connection's maxConn slot stays reserved across
+// attempts, so the Get is sure to get a dial in but an outage keeps slots
+// tied up for the whole retry sequence. Without it the slot is released as
+// soon as an attempt fails and taken again for the next one, which gives up
+// with the last dial error if the pool filled up meanwhile. Only dial
+// failures are retried, not an open circuit or an ended context.
+func WithDialRetry(retries int, backoff time.Duration, holdSlot bool) Option {
+	return func(o *options) {
+		o.dialRetry = dialRetry{retries: retries, backoff: backoff, holdSlot: holdSlot}
+	}
+}
+
+// WithConnState calls fn, without the pool lock held, each time a connection
+// is dialed, pooled, lent out or closed.
+func WithConnState(fn func(*IdleClient, ConnState)) Option {
+	return func(o *options) {
+		o.connState = fn
+	}
+}
+
+// WithReapDeadConns makes each reaper sweep also evict idle connections that
+// fail Check, whatever their age and regardless of MinIdle. Check only looks
+// at the socket's state, so the sweep stays off the network.
+func WithReapDeadConns() Option {
+	return func(o *options) {
+		o.reapDead = true
+	}
+}
+
+// WithTenantKey keeps connections apart by tenant: GetContext asks fn for
+// the tenant of ctx and serves each non-empty one from its own sub-pool, so a
+// connection dialed for one tenant is never handed to another. The sub-pools
+// share the pool's options. With perTenant each tenant may open maxConn
+// connections; otherwise maxConn bounds all tenants together, and a Get
+// finding it reached closes another tenant's idle connection before failing or
+// waiting. Put and
+// CloseErrConn route connections back to their sub-pool, and Release
+// releases them all.
+func WithTenantKey(fn func(ctx context.Context) string, perTenant bool) Option {
+	return func(o *options) {
+		o.tenantKey = fn
+		o.tenantPerPool = perTenant
+	}
+}
+
+// WithDialRateLimit lets new connections be dialed at no more than perSecond
+// on average, with bursts of up to burst. A dial beyond the rate waits for
+// its turn, bounded by its context, and fails right away with
+// ErrDialRateLimited if the context's deadline would pass first. The
+// connection's maxConn slot is reserved while it waits.
+func WithDialRateLimit(perSecond float64, burst int) Option {
+	return func(o *options) {
+		o.dialRate = perSecond
+		o.dialBurst = burst
+	}
+}
+
+// WithHealthThresholds makes Health report Degraded once at least errorRate
+// of the dials over the last window failed. They default to HEALTHERRORRATE
+// and HEALTHWINDOW seconds; window is capped at WINDOWBUCKETS seconds.
+func WithHealthThresholds(errorRate float64, window time.Duration) Option {
+	return func(o *options) {
+		o.healthErrorRate = errorRate
+		o.healthWindow = window
+	}
+}
+
+// WithEntryReuse recycles the IdleEntry bookkeeping of connections handed out
+// by Get for later Puts, sparing an allocation per round trip. Custom
+// IdleStores must not keep entries after Take or Remove returns them.
+func WithEntryReuse() Option {
+	return func(o *options) {
+		o.reuseEntries = true
+	}
+}
+
+// WithRandSeed seeds the pool's own random source, used for idle sampling,
+// so tests can reproduce which connections get picked. Without it every pool
+// is seeded differently.
+func WithRandSeed(seed int64) Option {
+	return func(o *options) {
+		o.randSeed = seed
+		o.seeded = true
+	}
+}
+
+// WithOnStaleReuse calls fn whenever Get hands out a connection that has been
+// idle longer than after, typically set a little under the server's idle
+// timeout. It is advisory: the connection is still returned, fn only gets the
+// chance to validate it or arrange a retry.
+func WithOnStaleReuse(after time.Duration, fn func(c *IdleClient, idleFor time.Duration)) Option {
+	return func(o *options) {
+		o.staleReuseAfter = after
+		o.onStaleReuse = fn
+	}
+}
+
+// WithHappyEyeballs makes dials through WithResolve race the resolved
+// addresses instead of trying them one after another: each attempt starts
+// delay after the previous one, or as soon as it fails, and the first
+// connection made wins while the others are closed. RFC 8305 suggests a
+// delay of 250ms.
+func WithHappyEyeballs(delay time.Duration) Option {
+	return func(o *options) {
+		o.happyEyeballs = delay
+	}
+}
+
+// WithMaxWaiters bounds how many Gets may queue on a full blocking pool.
+// Further Gets fail at once with ErrTooManyWaiters, so callers piling up
+// during a backend outage see backpressure instead of parking without limit.
+func WithMaxWaiters(n int) Option {
+	return func(o *options) {
+		o.maxWaiters = n
+	}
+}
+
+// WithValidateOnPut runs the WithValidate check on every connection Put
+// returns before pooling it, closing it if the check fails. With workers zero
+// the check runs inside Put. Otherwise Put queues the connection, up to queue
+// of them, for that many background workers, and only those that pass
+// become idle; Put validates inline when the queue is full, and always for
+// PutVerbose.
+func WithValidateOnPut(workers, queue int) Option {
+	return func(o *options) {
+		o.validateOnPut = true
+		o.putValidateWorkers = workers
+		o.putValidateQueue = queue
+	}
+}
+
+// WithReleaseOrder sets whether Release closes the oldest or the newest idle
+// connections first; the default is ReleaseOldestFirst. Closes run with
+// WithReleaseConcurrency in flight, so the order is strict only with a
+// concurrency of one.
+func WithReleaseOrder(order ReleaseOrder) Option {
+	return func(o *options) {
+		o.releaseOrder = order
+	}
+}
+
+// WithMaxConnErrors closes a connection instead of pooling it once it has
+// been returned with PutResult false n times, shedding connections that keep
+// failing though they pass Check.
+func WithMaxConnErrors(n uint32) Option {
+	return func(o *options) {
+		o.maxConnErrors = n
+	}
+}
+
+// WithSuggestHeadroom sets the fraction SuggestMaxConn adds over the observed
+// peak, SUGGESTHEADROOM by default.
+func WithSuggestHeadroom(f float64) Option {
+	return func(o *options) {
+		o.suggestHeadroom = f
+	}
+}
+
+// WithPerAddrMaxConn caps the connections to any one address of a
+// multi-address pool at n, dials in flight included, so a slow backend
+// holding on to its connections can't crowd out the others: new dials go to
+// the next address with room, and fail with ErrAddrFull when none has any.
+// AddrConns reports the counts.
+func WithPerAddrMaxConn(n uint32) Option {
+	return func(o *options) {
+		o.perAddrMaxConn = n
+	}
+}
+
+// WithOnResize calls fn after SetMaxConn or Apply changes MaxConn, with the
+// old and new value, outside the pool lock. It isn't called when MaxConn is
+// set to what it already was.
+func WithOnResize(fn func(old, new uint32)) Option {
+	return func(o *options) {
+		o.onResize = fn
+	}
+}
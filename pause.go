@@ -0,0 +1,40 @@
+package thriftpool
+
+// PauseReaper stops the reaper from evicting and sampling idle connections
+// until ResumeReaper. Borrow time checks and the MinIdle fill go on.
+func (p *ThriftPool) PauseReaper() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if !p.paused {
+		p.paused = true
+		p.pausedAt = p.now()
+	}
+}
+
+// ResumeReaper undoes PauseReaper and runs a sweep right away. Time spent
+// paused doesn't count as idle time, so connections that would have expired
+// during the pause aren't all evicted at once on resume.
+func (p *ThriftPool) ResumeReaper() {
+	p.lock.Lock()
+	if !p.paused {
+		p.lock.Unlock()
+		return
+	}
+	now := p.now()
+	paused := now.Sub(p.pausedAt)
+	p.idle.Each(func(e *IdleEntry) bool {
+		if e.Since.Before(p.pausedAt) {
+			e.Since = e.Since.Add(paused)
+		} else {
+			e.Since = now
+		}
+		return true
+	})
+	p.paused = false
+	p.lock.Unlock()
+
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,69 @@
+package thriftpool
+
+// pickUnderCapLocked returns the first address, in round robin order from n,
+// with connections to spare under the WithPerAddrMaxConn cap, or the nth if
+// there is no cap.
+func (p *ThriftPool) pickUnderCapLocked(n uint32) (string, bool) {
+	for i := 0; i < len(p.addrs); i++ {
+		addr := p.addrs[(n+uint32(i))%uint32(len(p.addrs))]
+		if p.opts.perAddrMaxConn == 0 || p.addrConns[addr] < p.opts.perAddrMaxConn {
+			return addr, true
+		}
+	}
+	return "", false
+}
+
+// reserveAddrLocked counts a dial to target against its cap, failing if the
+// cap is reached.
+func (p *ThriftPool) reserveAddrLocked(target string) bool {
+	if max := p.opts.perAddrMaxConn; max > 0 && p.addrConns[target] >= max {
+		return false
+	}
+	p.addrConns[target] += 1
+	return true
+}
+
+func (p *ThriftPool) unreserveAddrLocked(target string) {
+	switch p.addrConns[target] {
+	case 0:
+	case 1:
+		delete(p.addrConns, target)
+	default:
+		p.addrConns[target] -= 1
+	}
+}
+
+// trackLocked counts c, dialed by another pool, against its address in p.
+func (p *ThriftPool) trackLocked(c *IdleClient) {
+	if c.target == "" {
+		return
+	}
+	p.addrConns[c.target] += 1
+	c.tracked = p
+}
+
+// untrack takes c out of the per-address counts of the pool that has it.
+func untrack(c *IdleClient) {
+	t := c.tracked
+	if t == nil {
+		return
+	}
+	t.lock.Lock()
+	if c.tracked == t {
+		t.unreserveAddrLocked(c.target)
+		c.tracked = nil
+	}
+	t.lock.Unlock()
+}
+
+// AddrConns returns the number of connections, including dials in flight,
+// to each address, for checking WithPerAddrMaxConn.
+func (p *ThriftPool) AddrConns() map[string]uint32 {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	conns := make(map[string]uint32, len(p.addrConns))
+	for addr, n := range p.addrConns {
+		conns[addr] = n
+	}
+	return conns
+}
@@ -0,0 +1,108 @@
+package thriftpool_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/lehaisonmath6/thriftpool"
+	"github.com/lehaisonmath6/thriftpool/thriftpooltest"
+)
+
+func TestPerAddrMaxConnReleasesBrokenIdle(t *testing.T) {
+	d := &thriftpooltest.Dialer{}
+	var mu sync.Mutex
+	closed := 0
+	p := thriftpool.NewThriftPool("10.0.0.1", "1", 5, 1, 10, d.Dial, nil,
+		thriftpool.WithAddrs("10.0.0.2:1"), thriftpool.WithPerAddrMaxConn(1),
+		thriftpool.WithConnState(func(c *thriftpool.IdleClient, s thriftpool.ConnState) {
+			if s == thriftpool.StateClosed {
+				mu.Lock()
+				closed++
+				mu.Unlock()
+			}
+		}))
+	defer p.Release()
+
+	var cs []*thriftpool.IdleClient
+	for i := 0; i < 2; i++ {
+		c, err := p.Get()
+		if err != nil {
+			t.Fatal(err)
+		}
+		cs = append(cs, c)
+	}
+	if _, err := p.Get(); err != thriftpool.ErrAddrFull {
+		t.Fatalf("Get with both addresses full = %v, want ErrAddrFull", err)
+	}
+	for _, c := range cs {
+		p.Put(c)
+		thriftpooltest.Break(c)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := p.Get(); err != thriftpool.ErrSocketDisconnect {
+			t.Fatalf("Get of broken idle connection = %v, want ErrSocketDisconnect", err)
+		}
+	}
+	if n := p.GetConnCount(); n != 0 {
+		t.Fatalf("GetConnCount = %d, want 0", n)
+	}
+	if m := p.AddrConns(); len(m) != 0 {
+		t.Fatalf("AddrConns = %v, want none", m)
+	}
+	mu.Lock()
+	if closed != 2 {
+		t.Errorf("%d StateClosed reports, want 2", closed)
+	}
+	mu.Unlock()
+
+	for i := 0; i < 2; i++ {
+		if _, err := p.Get(); err != nil {
+			t.Fatalf("Get after broken connections were dropped: %v", err)
+		}
+	}
+}
+
+func TestPerAddrMaxConnSpillsToOtherAddr(t *testing.T) {
+	d := &thriftpooltest.Dialer{}
+	p := thriftpool.NewThriftPool("10.0.0.1", "1", 10, 1, 10, d.Dial, nil,
+		thriftpool.WithAddrs("10.0.0.2:1", "10.0.0.3:1"), thriftpool.WithPerAddrMaxConn(2))
+	defer p.Release()
+
+	// fill one address, leaving the extra demand to the other two
+	full := "10.0.0.1:1"
+	for len(p.AddrConns()) == 0 || p.AddrConns()[full] < 2 {
+		c, err := p.Get()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.Socket.Conn().RemoteAddr().String() != full {
+			p.CloseErrConn(c)
+		}
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 4)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := p.Get(); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("Get with room left on other addresses: %v", err)
+	}
+	for addr, n := range p.AddrConns() {
+		if n != 2 {
+			t.Errorf("%s has %d connections, want 2", addr, n)
+		}
+	}
+	if _, err := p.Get(); err != thriftpool.ErrAddrFull {
+		t.Errorf("Get with every address full = %v, want ErrAddrFull", err)
+	}
+}
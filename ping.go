@@ -0,0 +1,20 @@
+package thriftpool
+
+import "context"
+
+// PingContext borrows a connection, dialing one if needed, validates it with
+// the pool's validate function if one is set, and returns it, mirroring
+// database/sql's DB.PingContext.
+func (p *ThriftPool) PingContext(ctx context.Context) error {
+	client, err := p.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	if p.opts.validate != nil {
+		if err := p.opts.validate(client); err != nil {
+			p.CloseErrConn(client)
+			return err
+		}
+	}
+	return p.Put(client)
+}
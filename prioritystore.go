@@ -0,0 +1,131 @@
+package thriftpool
+
+import (
+	"container/heap"
+	"sort"
+	"time"
+)
+
+type priorityStore struct {
+	less    func(a, b *IdleClient) bool
+	entries []*IdleEntry
+	seq     uint64
+}
+
+// NewPriorityStore returns a store that hands out the connection less ranks
+// first, e.g. the most recently validated or the one on the fastest backend,
+// and among equals the one returned first. Use it through WithIdleStore.
+// Eviction still goes by idle time, oldest first, at the cost of a scan of
+// the whole store on each sweep.
+func NewPriorityStore(less func(a, b *IdleClient) bool) IdleStore {
+	return &priorityStore{less: less}
+}
+
+func (s *priorityStore) Len() int {
+	return len(s.entries)
+}
+
+func (s *priorityStore) Less(i, j int) bool {
+	a, b := s.entries[i], s.entries[j]
+	if s.less(a.Client, b.Client) {
+		return true
+	}
+	if s.less(b.Client, a.Client) {
+		return false
+	}
+	return a.seq < b.seq
+}
+
+func (s *priorityStore) Swap(i, j int) {
+	s.entries[i], s.entries[j] = s.entries[j], s.entries[i]
+	s.entries[i].index = i
+	s.entries[j].index = j
+}
+
+// Push and Pop are for container/heap only; the pool uses Put and Take.
+func (s *priorityStore) Push(x interface{}) {
+	e := x.(*IdleEntry)
+	e.index = len(s.entries)
+	s.entries = append(s.entries, e)
+}
+
+func (s *priorityStore) Pop() interface{} {
+	n := len(s.entries) - 1
+	e := s.entries[n]
+	s.entries[n] = nil
+	s.entries = s.entries[:n]
+	e.index = -1
+	return e
+}
+
+func (s *priorityStore) Put(e *IdleEntry) {
+	s.seq++
+	e.seq = s.seq
+	heap.Push(s, e)
+}
+
+func (s *priorityStore) holds(e *IdleEntry) bool {
+	return e.index >= 0 && e.index < len(s.entries) && s.entries[e.index] == e
+}
+
+func (s *priorityStore) Take(pred func(*IdleEntry) bool) *IdleEntry {
+	if len(s.entries) == 0 {
+		return nil
+	}
+	if pred == nil {
+		return heap.Pop(s).(*IdleEntry)
+	}
+	var taken *IdleEntry
+	s.Each(func(e *IdleEntry) bool {
+		if pred(e) {
+			taken = e
+			return false
+		}
+		return true
+	})
+	if taken != nil {
+		s.Remove(taken)
+	}
+	return taken
+}
+
+func (s *priorityStore) Remove(e *IdleEntry) bool {
+	if !s.holds(e) {
+		return false
+	}
+	heap.Remove(s, e.index)
+	return true
+}
+
+func (s *priorityStore) Evict(deadline time.Time, keep int) []*IdleEntry {
+	// the heap isn't ordered by age, so every entry has to be looked at
+	var evicted []*IdleEntry
+	for _, e := range s.entries {
+		if !e.Since.After(deadline) {
+			evicted = append(evicted, e)
+		}
+	}
+	sort.Slice(evicted, func(i, j int) bool { return evicted[i].Since.Before(evicted[j].Since) })
+	if n := len(s.entries) - keep; len(evicted) > n {
+		if n < 0 {
+			n = 0
+		}
+		evicted = evicted[:n]
+	}
+	for _, e := range evicted {
+		s.Remove(e)
+	}
+	return evicted
+}
+
+// Each visits a sorted copy, since the heap itself is only partially
+// ordered.
+func (s *priorityStore) Each(fn func(*IdleEntry) bool) {
+	sorted := &priorityStore{less: s.less, entries: append([]*IdleEntry(nil), s.entries...)}
+	sort.Slice(sorted.entries, sorted.Less)
+	for _, e := range sorted.entries {
+		if !fn(e) {
+			return
+		}
+	}
+}
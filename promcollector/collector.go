@@ -0,0 +1,68 @@
+// Package promcollector exposes a thriftpool.ThriftPool as a prometheus.Collector.
+// It is a module of its own so that importing thriftpool doesn't pull in the
+// Prometheus client.
+package promcollector
+
+import (
+	"github.com/lehaisonmath6/thriftpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type Collector struct {
+	pool *thriftpool.ThriftPool
+
+	idle       *prometheus.Desc
+	active     *prometheus.Desc
+	maxConn    *prometheus.Desc
+	gets       *prometheus.Desc
+	hits       *prometheus.Desc
+	misses     *prometheus.Desc
+	timeouts   *prometheus.Desc
+	dialErrors *prometheus.Desc
+	evictions  *prometheus.Desc
+}
+
+// New returns a collector reporting pool's Stats. constLabels distinguish
+// multiple pools registered with the same registry.
+func New(pool *thriftpool.ThriftPool, namespace string, constLabels prometheus.Labels) *Collector {
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "thriftpool", name), help, nil, constLabels)
+	}
+	return &Collector{
+		pool:       pool,
+		idle:       desc("idle_connections", "Number of idle connections in the pool."),
+		active:     desc("active_connections", "Number of open connections, idle or borrowed."),
+		maxConn:    desc("max_connections", "Maximum number of connections the pool may open."),
+		gets:       desc("gets_total", "Total number of Get calls."),
+		hits:       desc("hits_total", "Total number of Get calls served from an idle connection."),
+		misses:     desc("misses_total", "Total number of Get calls that dialed a new connection."),
+		timeouts:   desc("timeouts_total", "Total number of Gets that timed out waiting for or dialing a connection."),
+		dialErrors: desc("dial_errors_total", "Total number of failed dials."),
+		evictions:  desc("evictions_total", "Total number of idle connections evicted by the reaper."),
+	}
+}
+
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.idle
+	ch <- c.active
+	ch <- c.maxConn
+	ch <- c.gets
+	ch <- c.hits
+	ch <- c.misses
+	ch <- c.timeouts
+	ch <- c.dialErrors
+	ch <- c.evictions
+}
+
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	s := c.pool.Stats()
+	ch <- prometheus.MustNewConstMetric(c.idle, prometheus.GaugeValue, float64(s.Idle))
+	ch <- prometheus.MustNewConstMetric(c.active, prometheus.GaugeValue, float64(s.Active))
+	ch <- prometheus.MustNewConstMetric(c.maxConn, prometheus.GaugeValue, float64(s.MaxConn))
+	ch <- prometheus.MustNewConstMetric(c.gets, prometheus.CounterValue, float64(s.Gets))
+	ch <- prometheus.MustNewConstMetric(c.hits, prometheus.CounterValue, float64(s.Hits))
+	ch <- prometheus.MustNewConstMetric(c.misses, prometheus.CounterValue, float64(s.Misses))
+	ch <- prometheus.MustNewConstMetric(c.timeouts, prometheus.CounterValue, float64(s.Timeouts))
+	ch <- prometheus.MustNewConstMetric(c.dialErrors, prometheus.CounterValue, float64(s.DialErrors))
+	ch <- prometheus.MustNewConstMetric(c.evictions, prometheus.CounterValue, float64(s.Evictions))
+}
@@ -0,0 +1,10 @@
+module github.com/lehaisonmath6/thriftpool/promcollector
+
+go 1.16
+
+require (
+	github.com/lehaisonmath6/thriftpool v0.0.0
+	github.com/prometheus/client_golang v1.11.1
+)
+
+replace github.com/lehaisonmath6/thriftpool => ../
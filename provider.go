@@ -0,0 +1,42 @@
+package thriftpool
+
+import (
+	"context"
+	"sync"
+)
+
+// ClientProvider hands out clients without exposing IdleClient. Every client
+// acquired must be given back by calling its release func once with the
+// error, if any, its last call returned.
+type ClientProvider interface {
+	Acquire(ctx context.Context) (client interface{}, release func(err error), err error)
+}
+
+// AsProvider adapts p to ClientProvider. asClient picks what callers get out
+// of each connection, typically its thrift Client asserted to the service's
+// client type; a nil asClient hands out the *IdleClient itself. release
+// returns the connection with PutErr, so errors that poison it close it; calls
+// after the first are ignored.
+func AsProvider(p *ThriftPool, asClient func(*IdleClient) interface{}) ClientProvider {
+	if asClient == nil {
+		asClient = func(c *IdleClient) interface{} { return c }
+	}
+	return provider{pool: p, asClient: asClient}
+}
+
+type provider struct {
+	pool     *ThriftPool
+	asClient func(*IdleClient) interface{}
+}
+
+func (pr provider) Acquire(ctx context.Context) (interface{}, func(error), error) {
+	c, err := pr.pool.GetContext(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	var once sync.Once
+	release := func(err error) {
+		once.Do(func() { pr.pool.PutErr(c, err) })
+	}
+	return pr.asClient(c), release, nil
+}
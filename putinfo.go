@@ -0,0 +1,41 @@
+package thriftpool
+
+// PutInfo describes where PutVerbose left a connection.
+type PutInfo struct {
+	// Pooled is false if the connection was closed instead of pooled.
+	Pooled bool
+	// Position is how many idle connections are ahead of this one in the
+	// order IdleStore.Each visits them, which for the built-in stores is the
+	// order Get hands them out. IdleLen includes the connection itself.
+	Position int
+	IdleLen  int
+}
+
+// PutVerbose is Put, also reporting where in the idle store the connection
+// landed, for debugging reuse. The placement is logged as well when the pool
+// has a logger.
+func (p *ThriftPool) PutVerbose(client *IdleClient) (PutInfo, error) {
+	var info PutInfo
+	err := p.put(client, true, &info)
+	if info.Pooled {
+		p.logf("thriftpool: put connection at idle position %d of %d", info.Position, info.IdleLen)
+	} else if err == nil {
+		p.logf("thriftpool: put connection closed instead of pooled")
+	}
+	return info, err
+}
+
+func (info *PutInfo) fillLocked(p *ThriftPool, e *IdleEntry) {
+	info.Pooled = true
+	info.IdleLen = p.idle.Len()
+	info.Position = -1
+	i := 0
+	p.idle.Each(func(other *IdleEntry) bool {
+		if other == e {
+			info.Position = i
+			return false
+		}
+		i++
+		return true
+	})
+}
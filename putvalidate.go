@@ -0,0 +1,77 @@
+package thriftpool
+
+import "sync"
+
+type pendingPut struct {
+	client *IdleClient
+	ok     bool
+}
+
+// validateReturned runs the WithValidateOnPut check on a connection that
+// passed Put's own, with the lock released and client's slot still held.
+// Connections that pass go through putReturned once more, since the pool
+// may have changed meanwhile.
+func (p *ThriftPool) validateReturned(client *IdleClient, ok bool, info *PutInfo) error {
+	if info == nil {
+		p.putLock.RLock()
+		if p.putQueue != nil {
+			select {
+			case p.putQueue <- pendingPut{client, ok}:
+				p.putLock.RUnlock()
+				return nil
+			default:
+			}
+		}
+		p.putLock.RUnlock()
+	}
+	return p.promote(client, ok, info)
+}
+
+func (p *ThriftPool) promote(client *IdleClient, ok bool, info *PutInfo) error {
+	if err := p.opts.validate(client); err != nil {
+		p.lock.Lock()
+		p.releaseSlotLocked(false)
+		p.unlock()
+		p.closing(client, causeValidation)
+		p.discard(client)
+		return err
+	}
+	return p.putChecked(client, ok, info, false)
+}
+
+func (p *ThriftPool) startPutValidation() {
+	if p.opts.putValidateWorkers <= 0 || p.opts.validate == nil {
+		return
+	}
+	p.putLock.Lock()
+	defer p.putLock.Unlock()
+	if p.putQueue != nil {
+		return
+	}
+	queue := make(chan pendingPut, p.opts.putValidateQueue)
+	var wg sync.WaitGroup
+	wg.Add(p.opts.putValidateWorkers)
+	for i := 0; i < p.opts.putValidateWorkers; i++ {
+		go func() {
+			defer wg.Done()
+			for r := range queue {
+				p.promote(r.client, r.ok, nil)
+			}
+		}()
+	}
+	p.putQueue, p.putWorkers = queue, &wg
+}
+
+// stopPutValidation stops the workers after they have validated everything
+// queued.
+func (p *ThriftPool) stopPutValidation() {
+	p.putLock.Lock()
+	queue, workers := p.putQueue, p.putWorkers
+	p.putQueue, p.putWorkers = nil, nil
+	p.putLock.Unlock()
+
+	if queue != nil {
+		close(queue)
+		workers.Wait()
+	}
+}
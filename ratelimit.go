@@ -0,0 +1,89 @@
+package thriftpool
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrDialRateLimited = errors.New("ErrDialRateLimited")
+
+// tokenBucket paces dials for WithDialRateLimit. Tokens may go negative:
+// each caller reserves one and sleeps until it has accrued.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(perSecond float64, burst int) *tokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{
+		rate:   perSecond,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// reserve takes a token and returns how long to wait until it is due.
+func (b *tokenBucket) reserve(now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// cancel hands back a token reserved but not used.
+func (b *tokenBucket) cancel() {
+	b.mu.Lock()
+	b.tokens++
+	b.mu.Unlock()
+}
+
+// waitDialToken waits for a WithDialRateLimit token. It fails fast with
+// ErrDialRateLimited if ctx's deadline would pass first.
+func (p *ThriftPool) waitDialToken(ctx context.Context) error {
+	b := p.dialBucket
+	if b == nil {
+		return nil
+	}
+	now := time.Now()
+	wait := b.reserve(now)
+	if wait == 0 {
+		return nil
+	}
+	base := p.baseContext()
+	for _, c := range []context.Context{ctx, base} {
+		if deadline, ok := c.Deadline(); ok && now.Add(wait).After(deadline) {
+			b.cancel()
+			return ErrDialRateLimited
+		}
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		b.cancel()
+		return ctx.Err()
+	case <-base.Done():
+		b.cancel()
+		return base.Err()
+	}
+}
@@ -0,0 +1,60 @@
+package thriftpool_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lehaisonmath6/thriftpool"
+	"github.com/lehaisonmath6/thriftpool/thriftpooltest"
+)
+
+func TestDialRateLimit(t *testing.T) {
+	const rate, burst, n = 50, 2, 12
+	d := &thriftpooltest.Dialer{}
+	var mu sync.Mutex
+	var dials []time.Time
+	dial := func(ip, port string, timeout time.Duration) (*thriftpool.IdleClient, error) {
+		mu.Lock()
+		dials = append(dials, time.Now())
+		mu.Unlock()
+		return d.Dial(ip, port, timeout)
+	}
+	p := thriftpool.NewThriftPool("127.0.0.1", "9090", 100, 1, 10, dial, nil,
+		thriftpool.WithDialRateLimit(rate, burst))
+	defer p.Release()
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := p.Get(); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// however the dials are spread, none may run ahead of the bucket
+	mu.Lock()
+	defer mu.Unlock()
+	for i, at := range dials {
+		allowed := burst + rate*at.Sub(start).Seconds()
+		if float64(i+1) > allowed+0.5 {
+			t.Fatalf("dial %d came %v after the start, over the %v/s limit with burst %d",
+				i+1, at.Sub(start), rate, burst)
+		}
+	}
+	if el, min := time.Since(start), time.Duration(float64(n-burst)/rate*float64(time.Second)); el < min {
+		t.Errorf("%d dials took %v, want at least %v", n, el, min)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	if _, err := p.GetContext(ctx); err != thriftpool.ErrDialRateLimited {
+		t.Errorf("Get with a deadline before the next token = %v, want ErrDialRateLimited", err)
+	}
+}
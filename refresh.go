@@ -0,0 +1,54 @@
+package thriftpool
+
+import (
+	"context"
+	"time"
+)
+
+// RefreshIdle replaces every connection idle at the time of the call with a
+// freshly dialed one, one at a time with the refresh interval in between, so
+// that a redeployed backend sees new handshakes without a reconnect storm.
+// Connections borrowed meanwhile are left alone. It returns how many
+// connections were replaced, stopping early when ctx is done or a dial fails.
+func (p *ThriftPool) RefreshIdle(ctx context.Context) (int, error) {
+	p.lock.Lock()
+	var entries []*IdleEntry
+	p.idle.Each(func(e *IdleEntry) bool {
+		e.pinned = true
+		entries = append(entries, e)
+		return true
+	})
+	p.lock.Unlock()
+
+	n := 0
+	for i, e := range entries {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return n, ctx.Err()
+			case <-time.After(p.opts.refreshInterval):
+			}
+		}
+
+		p.lock.Lock()
+		if p.closed {
+			p.lock.Unlock()
+			return n, ErrPoolClosed
+		}
+		// skip connections borrowed or evicted since the snapshot; the
+		// slot of a removed one carries over to its replacement
+		if !p.idle.Remove(e) {
+			p.lock.Unlock()
+			continue
+		}
+		p.lock.Unlock()
+		p.closing(e.Client, causeRetired)
+		p.closeClient(e.Client)
+
+		if err := p.dialIdle(ctx); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
@@ -0,0 +1,105 @@
+package thriftpool
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// ReleaseOrder is the order in which Release starts closing idle
+// connections, by when they were dialed.
+type ReleaseOrder int
+
+const (
+	ReleaseOldestFirst ReleaseOrder = iota
+	ReleaseNewestFirst
+)
+
+// sortForRelease orders clients as WithReleaseOrder asks. Connections
+// dialed at the same time keep their idle list order.
+func (p *ThriftPool) sortForRelease(clients []*IdleClient) {
+	newest := p.opts.releaseOrder == ReleaseNewestFirst
+	sort.SliceStable(clients, func(i, j int) bool {
+		if newest {
+			return clients[i].createdAt.After(clients[j].createdAt)
+		}
+		return clients[i].createdAt.Before(clients[j].createdAt)
+	})
+}
+
+// ReleaseError reports the connections Release could not close cleanly.
+type ReleaseError struct {
+	Closed    int
+	Failed    int
+	Abandoned int
+	// Err is the first error returned by a close.
+	Err error
+}
+
+func (e *ReleaseError) Error() string {
+	msg := fmt.Sprintf("thriftpool: release closed %d connections, %d failed, %d abandoned",
+		e.Closed, e.Failed, e.Abandoned)
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *ReleaseError) Unwrap() error {
+	return e.Err
+}
+
+// closeAll closes clients with at most releaseConcurrency closes in flight,
+// starting them in slice order and giving up on the rest once
+// releaseTimeout has passed.
+func (p *ThriftPool) closeAll(clients []*IdleClient) error {
+	if len(clients) == 0 {
+		return nil
+	}
+
+	jobs := make(chan *IdleClient, len(clients))
+	for _, c := range clients {
+		jobs <- c
+	}
+	close(jobs)
+
+	// buffered so abandoned workers can still finish without blocking
+	results := make(chan error, len(clients))
+	workers := p.opts.releaseConcurrency
+	if workers > len(clients) {
+		workers = len(clients)
+	}
+	for i := 0; i < workers; i++ {
+		go func() {
+			for c := range jobs {
+				p.closing(c, causeShutdown)
+				results <- p.closeClient(c)
+			}
+		}()
+	}
+
+	timer := time.NewTimer(p.opts.releaseTimeout)
+	defer timer.Stop()
+
+	e := &ReleaseError{}
+	for done := 0; done < len(clients); done++ {
+		select {
+		case err := <-results:
+			if err != nil {
+				e.Failed++
+				if e.Err == nil {
+					e.Err = err
+				}
+			} else {
+				e.Closed++
+			}
+		case <-timer.C:
+			e.Abandoned = len(clients) - done
+			return e
+		}
+	}
+	if e.Failed > 0 {
+		return e
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package thriftpool_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lehaisonmath6/thriftpool"
+	"github.com/lehaisonmath6/thriftpool/thriftpooltest"
+)
+
+func TestReleaseConcurrentlyTwice(t *testing.T) {
+	d := &thriftpooltest.Dialer{}
+	// events, close errors and async close each own a channel Release closes
+	p := thriftpool.NewThriftPool("127.0.0.1", "9090", 4, 1, 10, d.Dial, nil,
+		thriftpool.WithEvents(8), thriftpool.WithCloseErrors(8), thriftpool.WithAsyncClose())
+	for i := 0; i < 4; i++ {
+		c, err := p.Get()
+		if err != nil {
+			t.Fatal(err)
+		}
+		p.Put(c)
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = p.Release()
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			t.Errorf("Release: %v", err)
+		}
+	}
+	if err := p.Release(); err != nil {
+		t.Errorf("third Release: %v", err)
+	}
+	if s := p.State(); s != thriftpool.PoolClosed {
+		t.Errorf("state %v after Release, want closed", s)
+	}
+	if n := d.Open(); n != 0 {
+		t.Errorf("%d connections open after Release, want 0", n)
+	}
+}
+
+func TestReleaseOrder(t *testing.T) {
+	for _, order := range []thriftpool.ReleaseOrder{thriftpool.ReleaseOldestFirst, thriftpool.ReleaseNewestFirst} {
+		d := &thriftpooltest.Dialer{}
+		clk := thriftpooltest.NewClock(time.Unix(1000, 0))
+		var closed []*thriftpool.IdleClient
+		closeFn := func(c *thriftpool.IdleClient) error {
+			closed = append(closed, c)
+			return c.Socket.Close()
+		}
+		p := thriftpool.NewThriftPool("127.0.0.1", "9090", 4, 1, 10, d.Dial, closeFn,
+			thriftpool.WithClock(clk.Now), thriftpool.WithIdleStore(thriftpool.NewLIFOStore),
+			thriftpool.WithReleaseOrder(order), thriftpool.WithReleaseConcurrency(1))
+
+		var dialed []*thriftpool.IdleClient
+		for i := 0; i < 4; i++ {
+			clk.Advance(time.Second)
+			c, _ := p.Get()
+			dialed = append(dialed, c)
+		}
+		// return them out of dial order so the idle list order differs
+		for _, i := range []int{2, 0, 3, 1} {
+			p.Put(dialed[i])
+		}
+		if err := p.Release(); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(closed) != len(dialed) {
+			t.Fatalf("order %v: %d closed, want %d", order, len(closed), len(dialed))
+		}
+		for i, c := range closed {
+			want := dialed[i]
+			if order == thriftpool.ReleaseNewestFirst {
+				want = dialed[len(dialed)-1-i]
+			}
+			if c != want {
+				t.Fatalf("order %v: close %d was not the connection dialed in turn", order, i)
+			}
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package thriftpool
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+)
+
+// dialResolved dials ip:port, or with WithResolve each address it resolves
+// to in turn, returning the last error if none can be dialed.
+func (p *ThriftPool) dialResolved(ctx context.Context, dial ThriftDial, ip, port string, timeout time.Duration) (*IdleClient, error) {
+	if p.opts.resolve == nil {
+		return dial(ip, port, timeout)
+	}
+	addrs, err := p.opts.resolve(ctx, ip, port)
+	if err != nil {
+		return nil, err
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("thriftpool: no addresses for %s", net.JoinHostPort(ip, port))
+	}
+	if p.opts.happyEyeballs > 0 && len(addrs) > 1 {
+		return p.dialRace(ctx, dial, addrs, timeout)
+	}
+	for _, addr := range addrs {
+		host, port, serr := net.SplitHostPort(addr.String())
+		if serr != nil {
+			err = serr
+			continue
+		}
+		var client *IdleClient
+		client, err = dial(host, port, timeout)
+		if err == nil {
+			return client, nil
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, err
+}
+
+// dialRace dials addrs in order, starting each attempt happyEyeballs after
+// the previous one or as soon as it fails, and returns the first connection
+// made. Connections that lose the race are closed as they complete.
+func (p *ThriftPool) dialRace(ctx context.Context, dial ThriftDial, addrs []net.Addr, timeout time.Duration) (*IdleClient, error) {
+	results := make(chan dialResult, len(addrs))
+	start := func(addr net.Addr) {
+		host, port, err := net.SplitHostPort(addr.String())
+		if err != nil {
+			results <- dialResult{err: err}
+			return
+		}
+		go func() {
+			client, err := dial(host, port, timeout)
+			results <- dialResult{client, err}
+		}()
+	}
+
+	started, pending := 1, 1
+	start(addrs[0])
+	next := time.NewTimer(p.opts.happyEyeballs)
+	defer next.Stop()
+
+	var err error
+	for pending > 0 {
+		select {
+		case r := <-results:
+			pending--
+			if r.err == nil {
+				go p.closeLosers(results, pending)
+				return r.client, nil
+			}
+			err = r.err
+			if started == len(addrs) {
+				continue
+			}
+		case <-next.C:
+			if started == len(addrs) {
+				continue
+			}
+		case <-ctx.Done():
+			go p.closeLosers(results, pending)
+			return nil, ctx.Err()
+		}
+		start(addrs[started])
+		started++
+		pending++
+		if !next.Stop() {
+			select {
+			case <-next.C:
+			default:
+			}
+		}
+		next.Reset(p.opts.happyEyeballs)
+	}
+	return nil, err
+}
+
+func (p *ThriftPool) closeLosers(results <-chan dialResult, pending int) {
+	p.lock.Lock()
+	closeFunc := p.Close
+	p.lock.Unlock()
+	if closeFunc == nil {
+		closeFunc = defaultClose
+	}
+	for ; pending > 0; pending-- {
+		if r := <-results; r.err == nil {
+			closeFunc(r.client)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package thriftpool_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/apache/thrift/lib/go/thrift"
+	"github.com/lehaisonmath6/thriftpool"
+	"github.com/lehaisonmath6/thriftpool/thriftpooltest"
+)
+
+func TestHappyEyeballsSkipsBlackHole(t *testing.T) {
+	const blackHole, reachable = "2001:db8::1", "192.0.2.1"
+	d := &thriftpooltest.Dialer{}
+	dial := func(ip, port string, timeout time.Duration) (*thriftpool.IdleClient, error) {
+		if ip != blackHole {
+			return d.Dial(ip, port, timeout)
+		}
+		// the route swallows the SYN until the dial times out
+		time.Sleep(timeout)
+		return nil, thrift.NewTTransportException(thrift.TIMED_OUT, "black hole")
+	}
+	resolve := func(ctx context.Context, host, port string) ([]net.Addr, error) {
+		return []net.Addr{
+			&net.TCPAddr{IP: net.ParseIP(blackHole), Port: 9090},
+			&net.TCPAddr{IP: net.ParseIP(reachable), Port: 9090},
+		}, nil
+	}
+	p := thriftpool.NewThriftPool("backend", "9090", 2, 1, 10, dial, nil,
+		thriftpool.WithResolve(resolve), thriftpool.WithHappyEyeballs(20*time.Millisecond),
+		thriftpool.WithDialTimeout(300*time.Millisecond))
+	defer p.Release()
+
+	start := time.Now()
+	c, err := p.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if el := time.Since(start); el > 200*time.Millisecond {
+		t.Errorf("Get took %v, waiting on the black-holed address", el)
+	}
+	if got := c.Socket.Conn().RemoteAddr().String(); got != net.JoinHostPort(reachable, "9090") {
+		t.Errorf("connected to %s, want the reachable address", got)
+	}
+	p.Put(c)
+
+	// the abandoned attempt times out on its own without touching the pool
+	time.Sleep(400 * time.Millisecond)
+	if s := p.Stats(); s.Active != 1 || s.Idle != 1 || d.Open() != 1 {
+		t.Errorf("%d active, %d idle, %d open, want 1 each", s.Active, s.Idle, d.Open())
+	}
+}
+
+func TestHappyEyeballsClosesLosers(t *testing.T) {
+	d := &thriftpooltest.Dialer{}
+	dial := func(ip, port string, timeout time.Duration) (*thriftpool.IdleClient, error) {
+		if ip == "192.0.2.1" {
+			// slow, but connects after the other address has won
+			time.Sleep(100 * time.Millisecond)
+		}
+		return d.Dial(ip, port, timeout)
+	}
+	resolve := func(ctx context.Context, host, port string) ([]net.Addr, error) {
+		return []net.Addr{
+			&net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 9090},
+			&net.TCPAddr{IP: net.ParseIP("192.0.2.2"), Port: 9090},
+		}, nil
+	}
+	closed := make(chan struct{}, 2)
+	closeFn := func(c *thriftpool.IdleClient) error {
+		defer func() { closed <- struct{}{} }()
+		return c.Socket.Close()
+	}
+	p := thriftpool.NewThriftPool("backend", "9090", 2, 1, 10, dial, closeFn,
+		thriftpool.WithResolve(resolve), thriftpool.WithHappyEyeballs(20*time.Millisecond))
+	defer p.Release()
+
+	c, err := p.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := c.Socket.Conn().RemoteAddr().String(); got != "192.0.2.2:9090" {
+		t.Errorf("connected to %s, want the faster address", got)
+	}
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("the losing connection was never closed")
+	}
+	if d.Dials() != 2 || d.Open() != 1 {
+		t.Errorf("%d dialed, %d open, want 2 and 1", d.Dials(), d.Open())
+	}
+	if n := p.GetConnCount(); n != 1 {
+		t.Errorf("%d connections counted, want 1", n)
+	}
+}
+
+func TestHappyEyeballsCancelIsNotDialFailure(t *testing.T) {
+	d := &thriftpooltest.Dialer{}
+	dial := func(ip, port string, timeout time.Duration) (*thriftpool.IdleClient, error) {
+		time.Sleep(100 * time.Millisecond)
+		return d.Dial(ip, port, timeout)
+	}
+	resolve := func(ctx context.Context, host, port string) ([]net.Addr, error) {
+		return []net.Addr{
+			&net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 9090},
+			&net.TCPAddr{IP: net.ParseIP("192.0.2.2"), Port: 9090},
+		}, nil
+	}
+	p := thriftpool.NewThriftPool("backend", "9090", 1, 1, 10, dial, nil,
+		thriftpool.WithResolve(resolve), thriftpool.WithHappyEyeballs(10*time.Millisecond),
+		thriftpool.WithCircuitBreaker(1, time.Hour))
+	defer p.Release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	if _, err := p.GetContext(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("Get cancelled mid-race = %v, want context.DeadlineExceeded", err)
+	}
+	// let the abandoned race finish
+	time.Sleep(50 * time.Millisecond)
+	if s := p.CircuitState(); s != thriftpool.CircuitClosed {
+		t.Errorf("circuit %v after a cancelled Get, want closed", s)
+	}
+	if n := p.Stats().DialErrors; n != 0 {
+		t.Errorf("%d dial errors after a cancelled Get, want 0", n)
+	}
+	c, err := p.Get()
+	if err != nil {
+		t.Fatalf("Get after a cancelled Get: %v", err)
+	}
+	p.Put(c)
+}
@@ -0,0 +1,60 @@
+package thriftpool
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+type dialRetry struct {
+	retries  int
+	backoff  time.Duration
+	holdSlot bool
+}
+
+// isDialFailure reports whether err is from the dial itself rather than from
+// the pool refusing or giving up on it.
+func isDialFailure(err error) bool {
+	var de *DialError
+	return err == ErrSocketDisconnect || errors.As(err, &de)
+}
+
+// retryWait sleeps for backoff before a dial retry. If ctx ends first, a
+// held slot is released.
+func (p *ThriftPool) retryWait(ctx context.Context, backoff time.Duration, overflow, hold bool) error {
+	var err error
+	base := p.baseContext()
+	select {
+	case <-time.After(backoff):
+		return nil
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-base.Done():
+		err = base.Err()
+	}
+	if hold {
+		p.lock.Lock()
+		p.releaseSlotLocked(overflow)
+		p.unlock()
+	}
+	return err
+}
+
+// retakeSlot reserves a slot again for a retry whose failed attempt released
+// it, failing if the pool filled up or closed meanwhile.
+func (p *ThriftPool) retakeSlot(overflow bool) bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	switch {
+	case p.closed:
+		return false
+	case overflow:
+		if p.overflow >= p.opts.overflow.burst {
+			return false
+		}
+		p.overflow += 1
+		return true
+	default:
+		return !p.fullLocked() && p.takeSlotLocked()
+	}
+}
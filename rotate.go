@@ -0,0 +1,144 @@
+package thriftpool
+
+import (
+	"math"
+	"net"
+	"sort"
+	"time"
+)
+
+// CloseOlderThan closes idle connections created more than age ago and marks
+// borrowed ones of the same age to be closed when they are returned. It
+// returns the number of connections affected. Whatever falls below MinIdle is
+// refilled by the reaper.
+func (p *ThriftPool) CloseOlderThan(age time.Duration) int {
+	return p.CloseWhere(func(_ net.Addr, connAge, _ time.Duration) bool {
+		return connAge > age
+	})
+}
+
+// CloseWhere closes the idle connections for which pred returns true and
+// marks matching borrowed ones to be closed when they are returned, returning
+// the number of connections affected. pred gets the connection's remote
+// address (nil if unknown), its age, and how long it has been idle, which is
+// zero for borrowed connections. It runs with the pool lock held.
+func (p *ThriftPool) CloseWhere(pred func(remote net.Addr, age, idleFor time.Duration) bool) int {
+	p.lock.Lock()
+	now := p.now()
+	match := func(c *IdleClient, idleFor time.Duration) bool {
+		var age time.Duration
+		if !c.createdAt.IsZero() {
+			age = now.Sub(c.createdAt)
+		}
+		return pred(c.remoteNetAddr(), age, idleFor)
+	}
+
+	var closing []*IdleEntry
+	p.idle.Each(func(e *IdleEntry) bool {
+		if match(e.Client, now.Sub(e.Since)) {
+			closing = append(closing, e)
+		}
+		return true
+	})
+	for _, e := range closing {
+		p.idle.Remove(e)
+		p.releaseSlotLocked(false)
+	}
+
+	n := len(closing)
+	for c := range p.borrowed {
+		if !c.retire && match(c, 0) {
+			c.retire = true
+			n++
+		}
+	}
+	p.unlock()
+
+	for _, e := range closing {
+		p.closing(e.Client, causeRetired)
+		p.closeClient(e.Client)
+	}
+	return n
+}
+
+// DrainFraction closes ceil(f * idle) of the idle connections, oldest first,
+// and returns how many it closed. Borrowed connections are left alone, so
+// calling it periodically cycles the pool gradually.
+func (p *ThriftPool) DrainFraction(f float64) int {
+	if f <= 0 {
+		return 0
+	}
+	if f > 1 {
+		f = 1
+	}
+
+	p.lock.Lock()
+	all := make([]*IdleEntry, 0, p.idle.Len())
+	p.idle.Each(func(e *IdleEntry) bool {
+		all = append(all, e)
+		return true
+	})
+	sort.SliceStable(all, func(i, j int) bool {
+		return all[i].Client.createdAt.Before(all[j].Client.createdAt)
+	})
+	closing := all[:int(math.Ceil(f*float64(len(all))))]
+	for _, e := range closing {
+		p.idle.Remove(e)
+		p.releaseSlotLocked(false)
+	}
+	p.unlock()
+
+	for _, e := range closing {
+		p.closing(e.Client, causeRetired)
+		p.emit(EventEvicted, e.Client, nil)
+		p.closeClient(e.Client)
+	}
+	return len(closing)
+}
+
+// Shrink closes idle connections, longest idle first, until MinIdle remain,
+// and returns how many it closed. Borrowed connections are left alone.
+func (p *ThriftPool) Shrink() int {
+	p.lock.Lock()
+	keep := p.minIdleLocked()
+	p.lock.Unlock()
+	return p.Trim(keep)
+}
+
+// Trim is Shrink down to keepIdle instead of MinIdle, as a building block for
+// giving memory back under pressure. Trimming below MinIdle lasts until the
+// reaper's next fill.
+func (p *ThriftPool) Trim(keepIdle uint32) int {
+	p.lock.Lock()
+	closing := p.idle.Evict(p.now(), int(keepIdle))
+	for range closing {
+		p.releaseSlotLocked(false)
+	}
+	p.unlock()
+
+	for _, e := range closing {
+		p.closing(e.Client, causeSurplus)
+		p.emit(EventEvicted, e.Client, nil)
+		p.closeClient(e.Client)
+	}
+	return len(closing)
+}
+
+// SetRejectPut switches the pool to closing every connection returned to it,
+// or back. Gets are served as usual, dialing if need be, so the pool drains
+// as its connections cycle through, without the abruptness of Release. While
+// set, the MinIdle floor is suspended: the reaper neither keeps nor refills
+// idle connections for it.
+func (p *ThriftPool) SetRejectPut(reject bool) {
+	p.lock.Lock()
+	p.rejectPut = reject
+	p.lock.Unlock()
+}
+
+// minIdleLocked is the MinIdle floor currently in force.
+func (p *ThriftPool) minIdleLocked() uint32 {
+	if p.rejectPut {
+		return 0
+	}
+	return p.opts.minIdle
+}
@@ -0,0 +1,74 @@
+package thriftpool
+
+import (
+	"math"
+	"math/rand"
+	"sync/atomic"
+	"time"
+)
+
+// poolSeeds is mixed into default seeds so pools created in the same clock
+// tick still draw different sequences.
+var poolSeeds int64
+
+func newPoolRand(o options) *rand.Rand {
+	seed := o.randSeed
+	if !o.seeded {
+		seed = time.Now().UnixNano() ^ atomic.AddInt64(&poolSeeds, 1)<<32
+	}
+	return rand.New(rand.NewSource(seed))
+}
+
+// sampleIdle validates a random sample of idle connections to catch ones the
+// server reset while they sat idle. The sampled connections are taken out of
+// the idle list while they are checked so no Get can borrow them meanwhile.
+func (p *ThriftPool) sampleIdle() {
+	if p.opts.validate == nil {
+		return
+	}
+
+	p.lock.Lock()
+	n := int(math.Ceil(p.opts.sampleFraction * float64(p.idle.Len())))
+	if n == 0 || p.closed {
+		p.lock.Unlock()
+		return
+	}
+	all := make([]*IdleEntry, 0, p.idle.Len())
+	p.idle.Each(func(e *IdleEntry) bool {
+		all = append(all, e)
+		return true
+	})
+	sample := make([]*IdleEntry, 0, n)
+	for _, i := range p.rand.Perm(len(all))[:n] {
+		p.idle.Remove(all[i])
+		sample = append(sample, all[i])
+	}
+	p.lock.Unlock()
+
+	for _, e := range sample {
+		err := p.opts.validate(e.Client)
+		p.lock.Lock()
+		cause := causeShutdown
+		if err == nil && !p.closed {
+			// connections returned meanwhile may have filled the idle list
+			lru, ok := p.admitIdleLocked(e.Since)
+			if ok {
+				p.pushIdleEntry(e)
+				p.lock.Unlock()
+				p.evictLRU(lru)
+				continue
+			}
+			cause = causeSurplus
+		}
+		p.releaseSlotLocked(false)
+		p.unlock()
+		if err != nil {
+			atomic.AddUint64(&p.stats.evictions, 1)
+			p.closing(e.Client, causeValidation)
+			p.emit(EventEvicted, e.Client, err)
+		} else {
+			p.closing(e.Client, cause)
+		}
+		p.closeClient(e.Client)
+	}
+}
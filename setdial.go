@@ -0,0 +1,38 @@
+package thriftpool
+
+// SetDial replaces the dial function for all later dials; dials in progress
+// finish with the old one. Pooled connections are kept, so follow it with
+// RefreshIdle to replace them promptly, e.g. after rotating credentials.
+// Unlike assigning p.Dial, it is safe while the pool is in use.
+func (p *ThriftPool) SetDial(dial ThriftDial) {
+	p.lock.Lock()
+	p.Dial = dial
+	tenants := p.tenantsLocked()
+	p.lock.Unlock()
+	for _, sub := range tenants {
+		sub.SetDial(dial)
+	}
+}
+
+// SetClose replaces the close function for all later closes. A nil closeFunc
+// restores the default, IdleClient.Close.
+func (p *ThriftPool) SetClose(closeFunc ThriftClientClose) {
+	if closeFunc == nil {
+		closeFunc = defaultClose
+	}
+	p.lock.Lock()
+	p.Close = closeFunc
+	tenants := p.tenantsLocked()
+	p.lock.Unlock()
+	for _, sub := range tenants {
+		sub.SetClose(closeFunc)
+	}
+}
+
+func (p *ThriftPool) tenantsLocked() []*ThriftPool {
+	subs := make([]*ThriftPool, 0, len(p.tenants))
+	for _, sub := range p.tenants {
+		subs = append(subs, sub)
+	}
+	return subs
+}
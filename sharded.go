@@ -0,0 +1,275 @@
+package thriftpool
+
+import (
+	"container/list"
+	"context"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// slotLimit enforces maxConn across the shards of a ShardedPool. Its mutex
+// only guards the waiters and is taken with a shard's lock held, never the
+// other way round.
+type slotLimit struct {
+	count uint32
+	// waiting mirrors waiters.Len() so signal can skip the mutex on the
+	// common path
+	waiting int32
+
+	mu      sync.Mutex
+	waiters list.List
+	wakeAll bool
+}
+
+func (l *slotLimit) take(max uint32) bool {
+	for {
+		n := atomic.LoadUint32(&l.count)
+		if n >= max {
+			return false
+		}
+		if atomic.CompareAndSwapUint32(&l.count, n, n+1) {
+			return true
+		}
+	}
+}
+
+func (l *slotLimit) release() {
+	atomic.AddUint32(&l.count, ^uint32(0))
+}
+
+func (l *slotLimit) addWaiter() (chan struct{}, *list.Element) {
+	ch := make(chan struct{}, 1)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	atomic.AddInt32(&l.waiting, 1)
+	return ch, l.waiters.PushBack(ch)
+}
+
+// signal wakes one waiter, or all of them for pools that can't serve each
+// other's waiters, see WithTenantKey.
+func (l *slotLimit) signal() {
+	if atomic.LoadInt32(&l.waiting) == 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.wakeAll {
+		l.signalLocked()
+		return
+	}
+	for l.waiters.Len() > 0 {
+		l.signalLocked()
+	}
+}
+
+func (l *slotLimit) signalLocked() {
+	if ele := l.waiters.Front(); ele != nil {
+		l.waiters.Remove(ele)
+		atomic.AddInt32(&l.waiting, -1)
+		ele.Value.(chan struct{}) <- struct{}{}
+	}
+}
+
+func (l *slotLimit) broadcast() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for l.waiters.Len() > 0 {
+		l.signalLocked()
+	}
+}
+
+// cancel removes a waiter that gives up, passing on a wakeup it already got.
+func (l *slotLimit) cancel(ch chan struct{}, ele *list.Element) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	select {
+	case <-ch:
+		l.signalLocked()
+	default:
+		l.waiters.Remove(ele)
+		atomic.AddInt32(&l.waiting, -1)
+	}
+}
+
+// ShardedPool spreads connections over GOMAXPROCS ThriftPools, each with its
+// own lock, so concurrent Gets and Puts mostly don't contend. maxConn bounds
+// the connections of all shards together, and a Get that finds its shard
+// empty takes an idle connection from another shard before dialing. Options
+// apply to each shard, so MinIdle and MaxIdle are per shard.
+type ShardedPool struct {
+	shards []*ThriftPool
+	limit  *slotLimit
+	block  bool
+	next   uint32
+}
+
+func NewShardedPool(ip, port string,
+	maxConn, connTimeout, idleTimeout uint32,
+	dial ThriftDial, closeFunc ThriftClientClose, opts ...Option) *ShardedPool {
+
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	s := &ShardedPool{
+		shards: make([]*ThriftPool, runtime.GOMAXPROCS(0)),
+		limit:  new(slotLimit),
+		block:  o.overflow.block,
+	}
+	// shards never block themselves; GetContext waits on the shared limit
+	o.overflow.block = false
+	o.limit = s.limit
+	for i := range s.shards {
+		s.shards[i] = newThriftPool(ip, port, maxConn,
+			time.Duration(connTimeout)*time.Second, time.Duration(idleTimeout)*time.Second,
+			dial, closeFunc, o)
+	}
+	return s
+}
+
+// Get is GetContext with the base context, see WithBaseContext.
+func (s *ShardedPool) Get() (*IdleClient, error) {
+	return s.GetContext(s.shards[0].baseContext())
+}
+
+func (s *ShardedPool) GetContext(ctx context.Context) (*IdleClient, error) {
+	n := uint32(len(s.shards))
+	start := atomic.AddUint32(&s.next, 1)
+	home := s.shards[start%n]
+	home.stats.add(statGets)
+	client, err := s.get(ctx, home, start)
+	if err != nil {
+		home.stats.add(statGetErrors)
+	}
+	return client, err
+}
+
+// get serves a Get counted against home, trying the shards' idle
+// connections from start on before dialing in home.
+func (s *ShardedPool) get(ctx context.Context, home *ThriftPool, start uint32) (*IdleClient, error) {
+	n := uint32(len(s.shards))
+	for {
+		// register before looking so a connection freed meanwhile isn't missed
+		var ch chan struct{}
+		var ele *list.Element
+		if s.block {
+			ch, ele = s.limit.addWaiter()
+		}
+
+		for i := uint32(0); i < n; i++ {
+			if client := s.shards[(start+i)%n].getIdle(); client != nil {
+				if ch != nil {
+					s.limit.cancel(ch, ele)
+				}
+				return client, nil
+			}
+		}
+		client, err := home.get(ctx)
+		if ch == nil || err != ErrOverMax {
+			if ch != nil {
+				s.limit.cancel(ch, ele)
+			}
+			return client, err
+		}
+
+		base := home.baseContext()
+		select {
+		case <-ch:
+			continue
+		case <-ctx.Done():
+			err = ctx.Err()
+		case <-base.Done():
+			err = base.Err()
+		}
+		s.limit.cancel(ch, ele)
+		if err == context.DeadlineExceeded {
+			home.stats.add(statTimeouts)
+		}
+		return nil, err
+	}
+}
+
+func (s *ShardedPool) Put(client *IdleClient) error {
+	if client == nil || client.home == nil {
+		return ErrInvalidConn
+	}
+	return client.home.Put(client)
+}
+
+func (s *ShardedPool) PutResult(client *IdleClient, ok bool) error {
+	if client == nil || client.home == nil {
+		return ErrInvalidConn
+	}
+	return client.home.PutResult(client, ok)
+}
+
+func (s *ShardedPool) CloseErrConn(client *IdleClient) {
+	if client == nil || client.home == nil {
+		return
+	}
+	client.home.CloseErrConn(client)
+}
+
+// Stats sums the shards' Stats.
+func (s *ShardedPool) Stats() Stats {
+	var sum Stats
+	for _, p := range s.shards {
+		st := p.Stats()
+		sum.Idle += st.Idle
+		sum.Active += st.Active
+		sum.MaxConn = st.MaxConn
+		sum.Overflow += st.Overflow
+		sum.Gets += st.Gets
+		sum.Hits += st.Hits
+		sum.Misses += st.Misses
+		sum.Timeouts += st.Timeouts
+		sum.DialErrors += st.DialErrors
+		sum.Evictions += st.Evictions
+		sum.EventsDropped += st.EventsDropped
+		sum.CloseErrorsDropped += st.CloseErrorsDropped
+		sum.OpenConnections += st.OpenConnections
+		sum.InUse += st.InUse
+		sum.WaitCount += st.WaitCount
+		sum.WaitDuration += st.WaitDuration
+		sum.MaxIdleClosed += st.MaxIdleClosed
+		sum.MaxIdleTimeClosed += st.MaxIdleTimeClosed
+		sum.MaxLifetimeClosed += st.MaxLifetimeClosed
+		sum.Closes.add(st.Closes)
+		// the shards queue on the same limit, so each sees all waiters
+		sum.Waiters = st.Waiters
+		sum.MaxWaiters = st.MaxWaiters
+	}
+	sum.Lifetimes = mergeLifetimes(s.shards)
+	return sum
+}
+
+// Release releases every shard, returning the first error.
+func (s *ShardedPool) Release() error {
+	var first error
+	for _, p := range s.shards {
+		if err := p.Release(); err != nil && first == nil {
+			first = err
+		}
+	}
+	s.limit.broadcast()
+	return first
+}
+
+// getIdle borrows an idle connection without dialing, or returns nil if none
+// is idle.
+func (p *ThriftPool) getIdle() *IdleClient {
+	for {
+		p.lock.Lock()
+		if p.closed || p.idle.Len() == 0 {
+			p.lock.Unlock()
+			return nil
+		}
+		e := p.idle.Take(nil)
+		p.lock.Unlock()
+		if client, err := p.reuse(e); err == nil {
+			return client
+		}
+	}
+}
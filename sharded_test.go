@@ -0,0 +1,72 @@
+package thriftpool_test
+
+import (
+	"testing"
+
+	"github.com/lehaisonmath6/thriftpool"
+	"github.com/lehaisonmath6/thriftpool/thriftpooltest"
+)
+
+type getPutter interface {
+	Get() (*thriftpool.IdleClient, error)
+	Put(*thriftpool.IdleClient) error
+	Release() error
+}
+
+func benchmarkParallelGetPut(b *testing.B, p getPutter) {
+	defer p.Release()
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			c, err := p.Get()
+			if err != nil {
+				b.Error(err)
+				return
+			}
+			p.Put(c)
+		}
+	})
+}
+
+func BenchmarkParallelGetPut(b *testing.B) {
+	opts := []thriftpool.Option{thriftpool.WithOverflowPolicy(thriftpool.Block)}
+	b.Run("single", func(b *testing.B) {
+		benchmarkParallelGetPut(b, thriftpool.NewThriftPool("127.0.0.1", "9090", 64, 1, 10,
+			thriftpooltest.FakeDial, thriftpooltest.FakeClose, opts...))
+	})
+	b.Run("sharded", func(b *testing.B) {
+		benchmarkParallelGetPut(b, thriftpool.NewShardedPool("127.0.0.1", "9090", 64, 1, 10,
+			thriftpooltest.FakeDial, thriftpooltest.FakeClose, opts...))
+	})
+}
+
+func TestShardedStatsSumsCloses(t *testing.T) {
+	s := thriftpool.NewShardedPool("127.0.0.1", "9090", 8, 1, 10,
+		thriftpooltest.FakeDial, thriftpooltest.FakeClose)
+	defer s.Release()
+
+	// spread over the shards by Get's round robin
+	const n = 6
+	var cs []*thriftpool.IdleClient
+	for i := 0; i < n; i++ {
+		c, err := s.Get()
+		if err != nil {
+			t.Fatal(err)
+		}
+		cs = append(cs, c)
+	}
+	for _, c := range cs {
+		s.CloseErrConn(c)
+	}
+
+	st := s.Stats()
+	if st.Closes.CallerError != n {
+		t.Errorf("Closes.CallerError = %d, want %d", st.Closes.CallerError, n)
+	}
+	if l := st.Lifetimes[thriftpool.CloseInvalid]; l.Count != n {
+		t.Errorf("Lifetimes[CloseInvalid].Count = %d, want %d", l.Count, n)
+	}
+	if st.Waiters != 0 {
+		t.Errorf("%d waiters with nothing queued", st.Waiters)
+	}
+}
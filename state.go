@@ -0,0 +1,41 @@
+package thriftpool
+
+type PoolState int
+
+const (
+	// PoolOpen pools serve Gets.
+	PoolOpen PoolState = iota
+	// PoolDraining pools have been released but still have borrowed
+	// connections outstanding; they are closed as they are returned.
+	PoolDraining
+	// PoolClosed pools have been released and hold no connections.
+	PoolClosed
+)
+
+func (s PoolState) String() string {
+	switch s {
+	case PoolOpen:
+		return "open"
+	case PoolDraining:
+		return "draining"
+	case PoolClosed:
+		return "closed"
+	}
+	return "unknown"
+}
+
+func (p *ThriftPool) State() PoolState {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return p.stateLocked()
+}
+
+func (p *ThriftPool) stateLocked() PoolState {
+	switch {
+	case !p.closed:
+		return PoolOpen
+	case p.count > 0 || p.overflow > 0:
+		return PoolDraining
+	}
+	return PoolClosed
+}
@@ -0,0 +1,150 @@
+package thriftpool
+
+import (
+	"errors"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/apache/thrift/lib/go/thrift"
+)
+
+// Stats is a point-in-time snapshot of a pool's gauges and cumulative counters.
+type Stats struct {
+	Idle   uint32
+	Active uint32
+	// MaxConn is zero for an unlimited pool.
+	MaxConn  uint32
+	Overflow uint32
+	// Waiters is the number of Gets queued for a full pool; MaxWaiters is
+	// zero unless bounded WithMaxWaiters.
+	Waiters    int
+	MaxWaiters int
+
+	Gets       uint64
+	Hits       uint64
+	Misses     uint64
+	Timeouts   uint64
+	DialErrors uint64
+	Evictions  uint64
+
+	EventsDropped      uint64
+	CloseErrorsDropped uint64
+
+	// The fields below mirror database/sql.DBStats.
+	OpenConnections   uint32
+	InUse             uint32
+	WaitCount         uint64
+	WaitDuration      time.Duration
+	MaxIdleClosed     uint64
+	MaxIdleTimeClosed uint64
+	MaxLifetimeClosed uint64
+
+	// Lifetimes has an entry for each CloseReason connections were closed
+	// for so far.
+	Lifetimes map[CloseReason]LifetimeSummary
+	Closes    CloseCauses
+}
+
+// counters is allocated separately so its uint64 fields stay 64-bit aligned
+// for atomic access on 32-bit platforms.
+type counters struct {
+	gets       uint64
+	hits       uint64
+	misses     uint64
+	timeouts   uint64
+	dialErrors uint64
+	evictions  uint64
+
+	eventsDropped    uint64
+	closeErrsDropped uint64
+
+	waitCount         uint64
+	waitDuration      uint64
+	maxIdleClosed     uint64
+	maxIdleTimeClosed uint64
+	maxLifetimeClosed uint64
+
+	causes [numCloseCauses]uint64
+
+	window    window
+	lifetimes lifetimes
+	now       func() time.Time
+}
+
+// add counts one f both in the cumulative counter and in the current
+// WindowedStats bucket.
+func (c *counters) add(f statField) {
+	switch f {
+	case statGets:
+		atomic.AddUint64(&c.gets, 1)
+	case statHits:
+		atomic.AddUint64(&c.hits, 1)
+	case statMisses:
+		atomic.AddUint64(&c.misses, 1)
+	case statTimeouts:
+		atomic.AddUint64(&c.timeouts, 1)
+	case statDialErrors:
+		atomic.AddUint64(&c.dialErrors, 1)
+	}
+	c.window.add(f, c.now())
+}
+
+func (c *counters) dialFailed(err error) {
+	c.add(statDialErrors)
+	if isTimeout(err) {
+		c.add(statTimeouts)
+	}
+}
+
+func isTimeout(err error) bool {
+	var te thrift.TTransportException
+	if errors.As(err, &te) && te.TypeId() == thrift.TIMED_OUT {
+		return true
+	}
+	var ne net.Error
+	if errors.As(err, &ne) && ne.Timeout() {
+		return true
+	}
+	return false
+}
+
+func (p *ThriftPool) Stats() Stats {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return p.statsLocked()
+}
+
+func (p *ThriftPool) statsLocked() Stats {
+	s := Stats{
+		Idle:     uint32(p.idle.Len()),
+		Active:   p.count,
+		MaxConn:  p.maxConn,
+		Overflow: p.overflow,
+
+		Waiters:    p.waitersLocked(),
+		MaxWaiters: p.opts.maxWaiters,
+	}
+	s.OpenConnections = p.count + p.overflow
+	s.InUse = s.OpenConnections - s.Idle
+	if p.opts.unlimited {
+		s.MaxConn = 0
+	}
+
+	s.Gets = atomic.LoadUint64(&p.stats.gets)
+	s.Hits = atomic.LoadUint64(&p.stats.hits)
+	s.Misses = atomic.LoadUint64(&p.stats.misses)
+	s.Timeouts = atomic.LoadUint64(&p.stats.timeouts)
+	s.DialErrors = atomic.LoadUint64(&p.stats.dialErrors)
+	s.Evictions = atomic.LoadUint64(&p.stats.evictions)
+	s.EventsDropped = atomic.LoadUint64(&p.stats.eventsDropped)
+	s.CloseErrorsDropped = atomic.LoadUint64(&p.stats.closeErrsDropped)
+	s.WaitCount = atomic.LoadUint64(&p.stats.waitCount)
+	s.WaitDuration = time.Duration(atomic.LoadUint64(&p.stats.waitDuration))
+	s.MaxIdleClosed = atomic.LoadUint64(&p.stats.maxIdleClosed)
+	s.MaxIdleTimeClosed = atomic.LoadUint64(&p.stats.maxIdleTimeClosed)
+	s.MaxLifetimeClosed = atomic.LoadUint64(&p.stats.maxLifetimeClosed)
+	s.Lifetimes = p.stats.lifetimes.summary()
+	s.Closes = p.stats.closeCauses()
+	return s
+}
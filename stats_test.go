@@ -0,0 +1,90 @@
+package thriftpool_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/lehaisonmath6/thriftpool"
+	"github.com/lehaisonmath6/thriftpool/thriftpooltest"
+)
+
+// TestConcurrentReadsAndWrites is meant for -race: the read-only accessors
+// take the pool lock shared while Gets and Puts mutate the pool.
+func TestConcurrentReadsAndWrites(t *testing.T) {
+	d := &thriftpooltest.Dialer{}
+	p := thriftpool.NewThriftPool("127.0.0.1", "9090", 4, 1, 10, d.Dial, nil,
+		thriftpool.WithOverflowPolicy(thriftpool.Block), thriftpool.WithAddrs("127.0.0.2:9090"))
+
+	var writers, readers sync.WaitGroup
+	stop := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		writers.Add(1)
+		go func() {
+			defer writers.Done()
+			for j := 0; j < 100; j++ {
+				c, err := p.Get()
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				p.PutResult(c, j%7 != 0)
+			}
+		}()
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				s := p.Stats()
+				if s.Active > 4 {
+					t.Errorf("%d active over maxConn 4", s.Active)
+				}
+				p.Dump()
+				p.Health()
+				p.IdleByAddr()
+				p.AddrConns()
+				p.GetConnCount()
+				p.State()
+				p.EachConn(func(thriftpool.ConnInfo) bool { return true })
+			}
+		}()
+	}
+	writers.Wait()
+	close(stop)
+	readers.Wait()
+
+	if err := p.Release(); err != nil {
+		t.Fatal(err)
+	}
+	if n := d.Open(); n != 0 {
+		t.Errorf("%d connections open after Release", n)
+	}
+}
+
+func TestHitsSkipDiscardedIdle(t *testing.T) {
+	d := &thriftpooltest.Dialer{}
+	p := thriftpool.NewThriftPool("127.0.0.1", "9090", 2, 1, 10, d.Dial, nil)
+	defer p.Release()
+
+	a, _ := p.Get()
+	b, _ := p.Get()
+	p.Put(a)
+	p.Put(b)
+	thriftpooltest.Break(a)
+
+	if _, err := p.Get(); err != thriftpool.ErrSocketDisconnect {
+		t.Fatalf("Get of broken idle connection = %v, want ErrSocketDisconnect", err)
+	}
+	c, err := p.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Put(c)
+	if n := p.Stats().Hits; n != 1 {
+		t.Errorf("%d hits, want 1 for the one idle connection handed out", n)
+	}
+}
@@ -0,0 +1,25 @@
+package thriftpool
+
+import "time"
+
+func (p *ThriftPool) startStatsLogging(stop chan struct{}) {
+	if p.opts.statsInterval <= 0 || p.opts.logger == nil {
+		return
+	}
+	go p.logStats(stop, p.opts.statsInterval)
+}
+
+func (p *ThriftPool) logStats(stop chan struct{}, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		s := p.Stats()
+		p.logf("thriftpool: %s: idle=%d active=%d max=%d overflow=%d gets=%d hits=%d misses=%d dial_errors=%d",
+			p.addrs[0], s.Idle, s.Active, s.MaxConn, s.Overflow, s.Gets, s.Hits, s.Misses, s.DialErrors)
+	}
+}
@@ -0,0 +1,47 @@
+package thriftpool
+
+import (
+	"math"
+	"time"
+)
+
+// SUGGESTHEADROOM is the default headroom SuggestMaxConn adds over the peak.
+const SUGGESTHEADROOM = 0.25
+
+// SuggestMaxConn recommends a MaxConn from the last WINDOWBUCKETS seconds of
+// use. It starts from the most connections borrowed at once, plus the
+// WithSuggestHeadroom fraction of it. If Gets had to wait for a connection
+// or timed out meanwhile, the peak was capped by MaxConn itself and says
+// little, so the suggestion is at least MaxConn plus the headroom. It never
+// suggests fewer than MinIdle or one. The result is advisory; nothing is
+// changed.
+func (p *ThriftPool) SuggestMaxConn() uint32 {
+	p.lock.RLock()
+	maxConn, minIdle := p.maxConn, p.opts.minIdle
+	p.lock.RUnlock()
+
+	now := p.now()
+	d := WINDOWBUCKETS * time.Second
+	n := p.stats.window.sum(now, d)
+	headroom := p.opts.suggestHeadroom
+
+	grow := func(base uint32) uint32 {
+		return uint32(math.Ceil(float64(base) * (1 + headroom)))
+	}
+	suggest := grow(p.stats.window.peak(now, d))
+	if n[statWaits] > 0 || n[statTimeouts] > 0 {
+		if atLeast := grow(maxConn); atLeast > suggest {
+			suggest = atLeast
+		}
+		if suggest == maxConn {
+			suggest++
+		}
+	}
+	if suggest < minIdle {
+		suggest = minIdle
+	}
+	if suggest < 1 {
+		suggest = 1
+	}
+	return suggest
+}
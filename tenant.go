@@ -0,0 +1,129 @@
+package thriftpool
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// tenantPool returns the sub-pool serving tenant, creating it on first use.
+func (p *ThriftPool) tenantPool(tenant string) (*ThriftPool, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.closed {
+		return nil, ErrPoolClosed
+	}
+	if sub := p.tenants[tenant]; sub != nil {
+		return sub, nil
+	}
+
+	o := p.opts
+	o.tenantKey = nil
+	sub := newThriftPool(p.ip, p.port, p.maxConn, p.connTimeout, p.idleTimeout, p.Dial, p.Close, o)
+	if !p.opts.tenantPerPool {
+		sub.tenantRoot = p
+	}
+	if p.tenants == nil {
+		p.tenants = make(map[string]*ThriftPool)
+	}
+	p.tenants[tenant] = sub
+	return sub, nil
+}
+
+// Tenant returns the sub-pool holding tenant's connections in a pool built
+// WithTenantKey, or nil if tenant has not borrowed any yet. Stats and the
+// other accessors of p itself only cover untagged connections.
+func (p *ThriftPool) Tenant(tenant string) *ThriftPool {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return p.tenants[tenant]
+}
+
+func (p *ThriftPool) tenantFor(ctx context.Context) string {
+	if p.opts.tenantKey == nil {
+		return ""
+	}
+	return p.opts.tenantKey(ctx)
+}
+
+// tenantLimitRoot returns the WithTenantKey pool whose maxConn p shares with
+// the other tenants, or nil if p's limit is its own.
+func (p *ThriftPool) tenantLimitRoot() *ThriftPool {
+	if p.tenantRoot != nil {
+		return p.tenantRoot
+	}
+	if p.opts.tenantKey != nil && !p.opts.tenantPerPool {
+		return p
+	}
+	return nil
+}
+
+// evictOtherTenant makes room under the shared maxConn for a Get of p by
+// closing the longest idle connection of the tenant holding the most idle
+// ones, rather than leaving p to wait for the idle timeout. It reports
+// whether it closed one.
+func (p *ThriftPool) evictOtherTenant() bool {
+	root := p.tenantLimitRoot()
+	if root == nil {
+		return false
+	}
+	root.lock.RLock()
+	pools := make([]*ThriftPool, 0, len(root.tenants)+1)
+	pools = append(pools, root)
+	for _, sub := range root.tenants {
+		pools = append(pools, sub)
+	}
+	root.lock.RUnlock()
+
+	var victim *ThriftPool
+	most := 0
+	for _, q := range pools {
+		if q == p {
+			continue
+		}
+		q.lock.RLock()
+		n := q.idle.Len()
+		q.lock.RUnlock()
+		if n > most {
+			victim, most = q, n
+		}
+	}
+	if victim == nil {
+		return false
+	}
+	return victim.evictOldestIdle()
+}
+
+func (p *ThriftPool) evictOldestIdle() bool {
+	p.lock.Lock()
+	evicted := p.idle.Evict(p.now(), p.idle.Len()-1)
+	if len(evicted) == 0 {
+		p.lock.Unlock()
+		return false
+	}
+	client := evicted[0].Client
+	p.freeIdleEntry(evicted[0])
+	p.releaseSlotLocked(false)
+	p.unlock()
+	atomic.AddUint64(&p.stats.evictions, 1)
+
+	p.closing(client, causeSurplus)
+	p.emit(EventEvicted, client, nil)
+	p.closeClient(client)
+	return true
+}
+
+// releaseTenants releases the tenant sub-pools, returning the first error.
+func (p *ThriftPool) releaseTenants() error {
+	p.lock.Lock()
+	tenants := p.tenants
+	p.tenants = nil
+	p.lock.Unlock()
+
+	var first error
+	for _, sub := range tenants {
+		if err := sub.Release(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
@@ -0,0 +1,71 @@
+package thriftpool_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/lehaisonmath6/thriftpool"
+	"github.com/lehaisonmath6/thriftpool/thriftpooltest"
+)
+
+type tenantKey struct{}
+
+func withTenant(tenant string) context.Context {
+	return context.WithValue(context.Background(), tenantKey{}, tenant)
+}
+
+func tenantOf(ctx context.Context) string {
+	s, _ := ctx.Value(tenantKey{}).(string)
+	return s
+}
+
+func TestSharedTenantLimitEvictsOtherTenantIdle(t *testing.T) {
+	d := &thriftpooltest.Dialer{}
+	p := thriftpool.NewThriftPool("127.0.0.1", "9090", 2, 1, 10, d.Dial, nil,
+		thriftpool.WithTenantKey(tenantOf, false))
+	defer p.Release()
+
+	var held []*thriftpool.IdleClient
+	for i := 0; i < 2; i++ {
+		c, err := p.GetContext(withTenant("a"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		held = append(held, c)
+	}
+	if _, err := p.GetContext(withTenant("b")); err != thriftpool.ErrOverMax {
+		t.Fatalf("Get with every connection borrowed = %v, want ErrOverMax", err)
+	}
+	a := p.Tenant("a")
+	for _, c := range held {
+		a.Put(c)
+	}
+
+	c, err := p.GetContext(withTenant("b"))
+	if err != nil {
+		t.Fatalf("Get with another tenant's connections idle: %v", err)
+	}
+	defer p.Tenant("b").Put(c)
+	if n := a.Stats().Idle; n != 1 {
+		t.Errorf("tenant a has %d idle connections, want 1", n)
+	}
+	if n := d.Open(); n != 2 {
+		t.Errorf("%d connections open, want 2", n)
+	}
+}
+
+func TestCloneForAddrOfTenantPoolHasOwnLimit(t *testing.T) {
+	d := &thriftpooltest.Dialer{}
+	p := thriftpool.NewThriftPool("127.0.0.1", "9090", 1, 1, 10, d.Dial, nil,
+		thriftpool.WithTenantKey(tenantOf, false))
+	defer p.Release()
+	if _, err := p.Get(); err != nil {
+		t.Fatal(err)
+	}
+
+	clone := p.CloneForAddr("127.0.0.2", "9090")
+	defer clone.Release()
+	if _, err := clone.GetContext(context.Background()); err != nil {
+		t.Fatalf("Get from clone of a full pool: %v", err)
+	}
+}
@@ -2,16 +2,25 @@ package thriftpool
 
 import (
 	"container/list"
+	"context"
 	"errors"
+	"math/rand"
 	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/apache/thrift/lib/go/thrift"
 )
 
 const (
-	CHECKINTERVAL = 60
+	CHECKINTERVAL      = 60
+	RELEASETIMEOUT     = 10
+	RELEASECONCURRENCY = 8
+	ASYNCCLOSEQUEUE    = 64
+	REFRESHINTERVAL    = 100 // milliseconds
+	HEALTHWINDOW       = 60  // seconds
+	HEALTHERRORRATE    = 0.2
 )
 
 type ThriftDial func(ip, port string, connTimeout time.Duration) (*IdleClient, error)
@@ -21,24 +30,102 @@ type ThriftPool struct {
 	Dial  ThriftDial
 	Close ThriftClientClose
 
-	lock        *sync.Mutex
-	idle        list.List
+	// lock guards the pool's state; read-only accessors such as Stats take
+	// it shared so they don't serialize with each other
+	lock      *sync.RWMutex
+	lifecycle sync.Mutex
+	// applyLock serializes Apply and SetMaxConn
+	applyLock sync.Mutex
+	stats     *counters
+	opts      options
+	idle      IdleStore
+	waiters   list.List
+	borrowed  map[*IdleClient]*borrowInfo
+	nextID    uint64
+	// addrConns counts connections and dials in flight per target address
+	addrConns map[string]uint32
+	// rand drives sampling; it is guarded by lock
+	rand *rand.Rand
+	// resolved holds the address the WithResolver function last returned,
+	// for WithRejectStaleAddr to check returned connections against
+	resolved    atomic.Value
 	idleTimeout time.Duration
 	connTimeout time.Duration
 	maxConn     uint32
 	count       uint32
 	ip          string
 	port        string
+	addrs       []string
+	nextAddr    uint32
+	overflow    uint32
+	dialing     uint32
+	dialSem     chan struct{}
+	dialBucket  *tokenBucket
 	closed      bool
+	rejectPut   bool
+	stop        chan struct{}
+	wake        chan struct{}
+	idleAdded   chan struct{}
+	tenants     map[string]*ThriftPool
+	tenantRoot  *ThriftPool
+	paused      bool
+	pausedAt    time.Time
+	breaker     breaker
+	eventLock   sync.RWMutex
+	events      chan PoolEvent
+	closeErrs   chan error
+	asyncLock   sync.RWMutex
+	asyncClose  chan *IdleClient
+	asyncDone   chan struct{}
+	putLock     sync.RWMutex
+	putQueue    chan pendingPut
+	putWorkers  *sync.WaitGroup
+
+	// hadConns is set once a connection is pooled or lent out, and
+	// cleared when the pool drains, so OnEmpty fires once per drain and not
+	// for dials that failed on an empty pool
+	hadConns     bool
+	emptyPending bool
 }
 
 type IdleClient struct {
 	Socket *thrift.TSocket
 	Client interface{}
+	// Transport is the transport Client was built on, if it wraps Socket,
+	// e.g. in framing or buffering. Close closes it before Socket.
+	Transport thrift.TTransport
+
+	revoked    bool
+	overflow   bool
+	retire     bool
+	createdAt  time.Time
+	lastActive time.Time
+	clock      func() time.Time
+	usage      int64
+	// errs counts the returns with PutResult false
+	errs uint32
+	// tracked is the pool whose per-address counts include the connection
+	tracked *ThriftPool
+	home    *ThriftPool
+	target  string
+	// deadlineSet is true while the socket timeout was changed for the
+	// current borrow, so Put restores the pool's
+	deadlineSet bool
+	meta        map[string]interface{}
+	closed      uint32
 }
 
+// SetConnTimeout is SetTimeout in whole seconds.
 func (c *IdleClient) SetConnTimeout(connTimeout uint32) {
-	c.Socket.SetTimeout(time.Duration(connTimeout) * time.Second)
+	c.SetTimeout(time.Duration(connTimeout) * time.Second)
+}
+
+// SetTimeout sets the socket timeout for the rest of the current borrow,
+// e.g. to give one long RPC more time. Put restores the pool's connTimeout,
+// so the next borrower isn't affected.
+func (c *IdleClient) SetTimeout(d time.Duration) error {
+	c.deadlineSet = true
+	return c.Socket.SetTimeout(d)
 }
 
 func (c *IdleClient) LocalAddr() net.Addr {
@@ -49,6 +136,63 @@ func (c *IdleClient) RemoteAddr() net.Addr {
 	return c.Socket.Conn().RemoteAddr()
 }
 
+// SetMeta attaches application state to the connection. It survives Put and
+// later Gets of the same connection. Like the rest of IdleClient it must only
+// be used by the goroutine currently holding the connection.
+func (c *IdleClient) SetMeta(key string, value interface{}) {
+	if c.meta == nil {
+		c.meta = make(map[string]interface{})
+	}
+	c.meta[key] = value
+}
+
+func (c *IdleClient) GetMeta(key string) (interface{}, bool) {
+	v, ok := c.meta[key]
+	return v, ok
+}
+
+// Errors is how many times the connection was returned with PutResult
+// false.
+func (c *IdleClient) Errors() uint32 {
+	return atomic.LoadUint32(&c.errs)
+}
+
+// AddUsage adds n to the usage the application attributes to the connection,
+// e.g. bytes transferred or requests served. Pools built WithMaxUsage close a
+// returned connection whose usage reached the limit. Only the goroutine
+// holding the connection may call it.
+func (c *IdleClient) AddUsage(n int64) {
+	c.usage += n
+}
+
+// Touch records that the connection just carried traffic, for pools built
+// WithIdleFromActivity. Only the goroutine holding the connection may call it.
+func (c *IdleClient) Touch() {
+	if c.clock == nil {
+		c.lastActive = nowFunc()
+		return
+	}
+	c.lastActive = c.clock()
+}
+
+// Close closes Transport, if set, and Socket, returning the first error.
+// Only the first call does anything.
+func (c *IdleClient) Close() error {
+	if !atomic.CompareAndSwapUint32(&c.closed, 0, 1) {
+		return nil
+	}
+	var err error
+	if c.Transport != nil {
+		err = c.Transport.Close()
+	}
+	if c.Socket != nil {
+		if serr := c.Socket.Close(); err == nil {
+			err = serr
+		}
+	}
+	return err
+}
+
 func (c *IdleClient) Check() bool {
 	if c.Socket == nil || c.Client == nil {
 		return false
@@ -56,213 +200,1235 @@ func (c *IdleClient) Check() bool {
 	return c.Socket.IsOpen()
 }
 
-type idleConn struct {
-	c *IdleClient
-	t time.Time
+func (c *IdleClient) remoteAddr() string {
+	if addr := c.remoteNetAddr(); addr != nil {
+		return addr.String()
+	}
+	return ""
+}
+
+func (c *IdleClient) remoteNetAddr() net.Addr {
+	if c.Socket == nil || c.Socket.Conn() == nil {
+		return nil
+	}
+	return c.Socket.Conn().RemoteAddr()
 }
 
 var nowFunc = time.Now
 
+func (p *ThriftPool) now() time.Time {
+	if p.opts.now != nil {
+		return p.opts.now()
+	}
+	return nowFunc()
+}
+
 var (
 	ErrOverMax          = errors.New("ErrOverMax")
 	ErrInvalidConn      = errors.New("ErrInvalidConn")
 	ErrPoolClosed       = errors.New("ErrPoolClosed")
 	ErrSocketDisconnect = errors.New("ErrSocketDisconnect")
+	ErrCircuitOpen      = errors.New("ErrCircuitOpen")
+	ErrTooManyWaiters   = errors.New("ErrTooManyWaiters")
+	ErrAddrFull         = errors.New("ErrAddrFull")
+
+	errDiscarded = errors.New("errDiscarded")
 )
 
+// NewThriftPool builds a pool for ip:port the way New does, from the same
+// options, but takes the basics as arguments and doesn't validate them.
 func NewThriftPool(ip, port string,
 	maxConn, connTimeout, idleTimeout uint32,
-	dial ThriftDial, closeFunc ThriftClientClose) *ThriftPool {
+	dial ThriftDial, closeFunc ThriftClientClose, opts ...Option) *ThriftPool {
+
+	o := collectOptions(opts)
+	o.dial, o.closeFunc = dial, closeFunc
+	o.maxConn = maxConn
+	o.connTimeout = time.Duration(connTimeout) * time.Second
+	o.idleTimeout = time.Duration(idleTimeout) * time.Second
+	o.addrs = append([]string{net.JoinHostPort(ip, port)}, o.addrs...)
+	return fromOptions(o)
+}
+
+// fromOptions builds the pool New and NewThriftPool describe: the first of
+// o.addrs is its ip:port.
+func fromOptions(o options) *ThriftPool {
+	var ip, port string
+	if len(o.addrs) > 0 {
+		ip, port, _ = net.SplitHostPort(o.addrs[0])
+		o.addrs = o.addrs[1:]
+	}
+	return newThriftPool(ip, port, o.maxConn, o.connTimeout, o.idleTimeout, o.dial, o.closeFunc, o)
+}
+
+func newThriftPool(ip, port string,
+	maxConn uint32, connTimeout, idleTimeout time.Duration,
+	dial ThriftDial, closeFunc ThriftClientClose, o options) *ThriftPool {
+
+	if closeFunc == nil {
+		closeFunc = defaultClose
+	}
+	if o.tenantKey != nil && !o.tenantPerPool && o.limit == nil {
+		o.limit = &slotLimit{wakeAll: true}
+	}
+	o.setDefaults()
 
 	thriftPool := &ThriftPool{
 		Dial:        dial,
 		Close:       closeFunc,
 		ip:          ip,
 		port:        port,
-		lock:        new(sync.Mutex),
+		lock:        new(sync.RWMutex),
+		stats:       new(counters),
+		opts:        o,
+		borrowed:    make(map[*IdleClient]*borrowInfo),
+		addrConns:   make(map[string]uint32),
+		addrs:       append([]string{net.JoinHostPort(ip, port)}, o.addrs...),
 		maxConn:     maxConn,
-		idleTimeout: time.Duration(idleTimeout) * time.Second,
-		connTimeout: time.Duration(connTimeout) * time.Second,
+		idleTimeout: idleTimeout,
+		connTimeout: connTimeout,
 		closed:      false,
+		stop:        make(chan struct{}),
+		wake:        make(chan struct{}, 1),
 		count:       0,
 	}
+	if thriftPool.opts.newIdleStore != nil {
+		thriftPool.idle = thriftPool.opts.newIdleStore()
+	} else {
+		thriftPool.idle = NewFIFOStore()
+	}
+	if thriftPool.opts.dialRate > 0 {
+		thriftPool.dialBucket = newTokenBucket(thriftPool.opts.dialRate, thriftPool.opts.dialBurst)
+	}
+	if thriftPool.opts.maxConcurrentDials > 0 {
+		thriftPool.dialSem = make(chan struct{}, thriftPool.opts.maxConcurrentDials)
+	}
+	thriftPool.rand = newPoolRand(thriftPool.opts)
+	thriftPool.stats.now = thriftPool.now
+	thriftPool.openEvents()
+	thriftPool.openCloseErrors()
+	thriftPool.startAsyncClose()
+	thriftPool.startPutValidation()
 
 	go thriftPool.ClearConn()
+	thriftPool.startStatsLogging(thriftPool.stop)
 
 	return thriftPool
 }
 
-func (p *ThriftPool) Get() (*IdleClient, error) {
+// CloneForAddr returns a new, empty pool for ip:port with the same
+// configuration, dial and close functions as p.
+func (p *ThriftPool) CloneForAddr(ip, port string) *ThriftPool {
 	p.lock.Lock()
-	if p.closed {
-		p.lock.Unlock()
-		return nil, ErrPoolClosed
+	o := p.opts
+	o.addrs = nil
+	// a clone for another address gets its own maxConn
+	o.limit = nil
+	dial, closeFunc := p.Dial, p.Close
+	maxConn, connTimeout, idleTimeout := p.maxConn, p.connTimeout, p.idleTimeout
+	p.lock.Unlock()
+
+	return newThriftPool(ip, port, maxConn, connTimeout, idleTimeout, dial, closeFunc, o)
+}
+
+// Get is GetContext with the pool's base context, see WithBaseContext.
+func (p *ThriftPool) Get() (*IdleClient, error) {
+	return p.GetContext(p.baseContext())
+}
+
+// GetContext borrows a connection. ctx bounds both the wait for a free slot
+// under the Block policy and the dial of a new connection; cancelling the
+// pool's base context aborts it as well.
+func (p *ThriftPool) GetContext(ctx context.Context) (*IdleClient, error) {
+	if tenant := p.tenantFor(ctx); tenant != "" {
+		sub, err := p.tenantPool(tenant)
+		if err != nil {
+			return nil, err
+		}
+		return sub.GetContext(ctx)
 	}
 
-	if p.idle.Len() == 0 && p.count >= p.maxConn {
-		p.lock.Unlock()
-		return nil, ErrOverMax
+	p.stats.add(statGets)
+	client, err := p.get(ctx)
+	if err == nil && p.opts.deadlinePropagation && client.Socket != nil {
+		if deadline, ok := ctx.Deadline(); ok {
+			// thrift takes a timeout of zero or less as none at all
+			left := time.Until(deadline)
+			if left <= 0 {
+				p.Put(client)
+				client, err = nil, context.DeadlineExceeded
+			} else {
+				client.Socket.SetTimeout(left)
+				client.deadlineSet = true
+			}
+		}
+	}
+	if err != nil {
+		p.stats.add(statGetErrors)
 	}
+	return client, err
+}
 
-	if p.idle.Len() == 0 {
-		dial := p.Dial
-		p.count += 1
-		p.lock.Unlock()
-		client, err := dial(p.ip, p.port, p.connTimeout)
-		if err != nil {
-			p.lock.Lock()
-			if p.count > 0 {
-				p.count -= 1
+func (p *ThriftPool) get(ctx context.Context) (*IdleClient, error) {
+	triedEvict := false
+	for {
+		if err := p.ctxErr(ctx); err != nil {
+			return nil, err
+		}
+
+		p.lock.Lock()
+		if p.closed {
+			p.lock.Unlock()
+			return nil, ErrPoolClosed
+		}
+
+		if p.idle.Len() == 0 && p.fullLocked() {
+			if p.overflow < p.opts.overflow.burst {
+				p.overflow += 1
+				p.lock.Unlock()
+				return p.dialNext(ctx, true)
+			}
+			if !triedEvict && p.tenantLimitRoot() != nil {
+				triedEvict = true
+				p.lock.Unlock()
+				p.evictOtherTenant()
+				continue
 			}
+			if !p.opts.overflow.block {
+				p.lock.Unlock()
+				p.emit(EventSaturated, nil, nil)
+				return nil, ErrOverMax
+			}
+			if max := p.opts.maxWaiters; max > 0 && p.waitersLocked() >= max {
+				p.lock.Unlock()
+				p.emit(EventSaturated, nil, nil)
+				return nil, ErrTooManyWaiters
+			}
+			ch, ele := p.addWaiterLocked()
 			p.lock.Unlock()
-			return nil, err
+			p.emit(EventSaturated, nil, nil)
+			if err := p.wait(ctx, ch, ele); err != nil {
+				return nil, err
+			}
+			triedEvict = false
+			continue
 		}
-		if !client.Check() {
+
+		if p.idle.Len() == 0 && p.opts.dialCoalesce > 0 {
+			if p.dialing >= p.opts.dialCoalesce {
+				ch, ele := p.addWaiterLocked()
+				p.lock.Unlock()
+				if err := p.wait(ctx, ch, ele); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			if !p.takeSlotLocked() {
+				p.lock.Unlock()
+				continue
+			}
+			p.dialing += 1
+			p.lock.Unlock()
+			client, err := p.dialNext(ctx, false)
 			p.lock.Lock()
-			if p.count > 0 {
-				p.count -= 1
+			p.dialing -= 1
+			// let a waiter dial in our place or pick up a returned connection
+			p.signalWaiterLocked()
+			p.lock.Unlock()
+			return client, err
+		}
+
+		if p.idle.Len() == 0 {
+			if !p.takeSlotLocked() {
+				p.lock.Unlock()
+				continue
 			}
 			p.lock.Unlock()
-			return nil, ErrSocketDisconnect
+			return p.dialNext(ctx, false)
 		}
-		return client, nil
-	} else {
-		ele := p.idle.Front()
-		idlec := ele.Value.(*idleConn)
-		p.idle.Remove(ele)
+
+		e := p.idle.Take(nil)
 		p.lock.Unlock()
+		client, err := p.reuse(e)
+		if err == errDiscarded {
+			continue
+		}
+		return client, err
+	}
+}
 
-		if !idlec.c.Check() {
-			p.lock.Lock()
-			if p.count > 0 {
-				p.count -= 1
+// GetForAddr prefers an idle connection whose RemoteAddr().String() equals
+// addr, dialing addr when none is idle. When addr can't be served, it falls
+// back to Get if the pool was built WithAffinityFallback, and errors otherwise.
+func (p *ThriftPool) GetForAddr(addr string) (*IdleClient, error) {
+	p.stats.add(statGets)
+	client, err := p.getForAddr(addr)
+	if err != nil {
+		p.stats.add(statGetErrors)
+	}
+	return client, err
+}
+
+func (p *ThriftPool) getForAddr(addr string) (*IdleClient, error) {
+	for {
+		p.lock.Lock()
+		if p.closed {
+			p.lock.Unlock()
+			return nil, ErrPoolClosed
+		}
+
+		if e := p.takeIdleAddrLocked(addr); e != nil {
+			p.lock.Unlock()
+			client, err := p.reuse(e)
+			if err == errDiscarded {
+				continue
+			}
+			return client, err
+		}
+
+		if p.fullLocked() {
+			p.lock.Unlock()
+			if p.opts.affinityFallback {
+				return p.get(p.baseContext())
 			}
+			p.emit(EventSaturated, nil, nil)
+			return nil, ErrOverMax
+		}
+
+		ip, port, err := net.SplitHostPort(addr)
+		if err != nil {
 			p.lock.Unlock()
-			return nil, ErrSocketDisconnect
+			return nil, err
+		}
+		if !p.takeSlotLocked() {
+			p.lock.Unlock()
+			continue
+		}
+		p.lock.Unlock()
+
+		client, err := p.dial(p.baseContext(), ip, port, false)
+		if err != nil && p.opts.affinityFallback {
+			return p.get(p.baseContext())
+		}
+		return client, err
+	}
+}
+
+// pickAddr returns the address for the next new connection, reserved
+// against its per-address count: the resolver's answer if one is set,
+// otherwise the next configured address in turn under the WithPerAddrMaxConn
+// cap. The address is picked and reserved under one lock, so concurrent dials
+// can't both take an address's last spare connection.
+func (p *ThriftPool) pickAddr() (string, string, error) {
+	ip, port := p.ip, p.port
+	if p.opts.resolver != nil {
+		var err error
+		if ip, port, err = p.callResolver(); err != nil {
+			return "", "", err
+		}
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.opts.resolver == nil && len(p.addrs) > 1 {
+		n := atomic.AddUint32(&p.nextAddr, 1)
+		addr, ok := p.pickUnderCapLocked(n)
+		if !ok {
+			return "", "", ErrAddrFull
+		}
+		if host, hport, err := net.SplitHostPort(addr); err == nil {
+			ip, port = host, hport
+		}
+	}
+	if !p.reserveAddrLocked(net.JoinHostPort(ip, port)) {
+		return "", "", ErrAddrFull
+	}
+	return ip, port, nil
+}
+
+// callResolver asks the WithResolver function for the current address and
+// remembers a successful answer for staleTarget.
+func (p *ThriftPool) callResolver() (string, string, error) {
+	ip, port, err := p.opts.resolver()
+	if err == nil {
+		p.resolved.Store(net.JoinHostPort(ip, port))
+	}
+	return ip, port, err
+}
+
+// staleTarget reports whether a pool built WithRejectStaleAddr now dials a
+// different address than the one client was dialed to. It goes by the
+// resolver's last answer, so Put doesn't wait on a lookup.
+func (p *ThriftPool) staleTarget(client *IdleClient) bool {
+	if !p.opts.rejectStale || p.opts.resolver == nil || client.target == "" {
+		return false
+	}
+	target, _ := p.resolved.Load().(string)
+	return target != "" && target != client.target
+}
+
+func (p *ThriftPool) dialNext(ctx context.Context, overflow bool) (*IdleClient, error) {
+	p.stats.add(statMisses)
+	retry := p.opts.dialRetry
+	for attempt := 0; ; attempt++ {
+		ip, port, err := p.pickAddr()
+		if err != nil {
+			p.lock.Lock()
+			p.releaseSlotLocked(overflow)
+			p.unlock()
+			return nil, err
+		}
+
+		hold := retry.holdSlot && attempt < retry.retries
+		client, err := p.openSlot(ctx, ip, port, overflow, hold)
+		if err == nil {
+			p.borrow(client)
+			return p.onBorrow(client)
+		}
+		if attempt >= retry.retries || !isDialFailure(err) {
+			return nil, err
+		}
+		if err := p.retryWait(ctx, retry.backoff, overflow, hold); err != nil {
+			return nil, err
+		}
+		if !hold && !p.retakeSlot(overflow) {
+			return nil, err
+		}
+	}
+}
+
+// dial opens a new connection to ip:port for a Get and marks it borrowed.
+func (p *ThriftPool) dial(ctx context.Context, ip, port string, overflow bool) (*IdleClient, error) {
+	p.stats.add(statMisses)
+	p.lock.Lock()
+	if !p.reserveAddrLocked(net.JoinHostPort(ip, port)) {
+		p.releaseSlotLocked(overflow)
+		p.unlock()
+		return nil, ErrAddrFull
+	}
+	p.lock.Unlock()
+	client, err := p.open(ctx, ip, port, overflow)
+	if err != nil {
+		return nil, err
+	}
+	p.borrow(client)
+	return p.onBorrow(client)
+}
+
+// open dials a new connection to ip:port. The caller must already have
+// reserved a slot in p.count, or in p.overflow for a temporary connection,
+// and ip:port against its per-address count, as pickAddr does; both are
+// released if the dial fails. The dial gets the dial timeout, or
+// connTimeout if none is set, capped by the time left before ctx's deadline;
+// the socket is then set to the pool's connTimeout. If ctx
+// is done before the dial returns, open gives up and adoptDial takes over
+// the dial's result.
+func (p *ThriftPool) open(ctx context.Context, ip, port string, overflow bool) (*IdleClient, error) {
+	return p.openSlot(ctx, ip, port, overflow, false)
+}
+
+// openSlot is open, but with hold set a failed dial keeps its slot for a
+// retry. Other failures release it all the same.
+func (p *ThriftPool) openSlot(ctx context.Context, ip, port string, overflow, hold bool) (*IdleClient, error) {
+	target := net.JoinHostPort(ip, port)
+	p.lock.Lock()
+	if !p.breakerAllowLocked(p.now()) {
+		p.unreserveAddrLocked(target)
+		p.releaseSlotLocked(overflow)
+		p.unlock()
+		return nil, ErrCircuitOpen
+	}
+	probe := p.breaker.probing
+	dial := p.Dial
+	connTimeout := p.connTimeout
+	limit := connTimeout
+	if p.opts.dialTimeout > 0 {
+		limit = p.opts.dialTimeout
+	}
+	p.lock.Unlock()
+
+	if err := p.waitDialToken(ctx); err != nil {
+		p.lock.Lock()
+		p.releaseSlotLocked(overflow)
+		p.probeAbandonedLocked(probe)
+		p.unreserveAddrLocked(target)
+		p.unlock()
+		return nil, err
+	}
+	if err := p.acquireDial(ctx); err != nil {
+		p.lock.Lock()
+		p.releaseSlotLocked(overflow)
+		p.probeAbandonedLocked(probe)
+		p.unreserveAddrLocked(target)
+		p.unlock()
+		return nil, err
+	}
+
+	timeout, err := p.dialTimeout(ctx, limit)
+	if err != nil {
+		p.releaseDial()
+		p.lock.Lock()
+		p.releaseSlotLocked(overflow)
+		p.probeAbandonedLocked(probe)
+		p.unreserveAddrLocked(target)
+		p.unlock()
+		if !isContextErr(err) {
+			p.stats.dialFailed(err)
+		}
+		return nil, err
+	}
+
+	base := p.baseContext()
+	if ctx.Done() == nil && base.Done() == nil {
+		client, err := p.dialResolved(ctx, dial, ip, port, timeout)
+		p.releaseDial()
+		return p.opened(ip, port, client, err, timeout, connTimeout, overflow, hold, probe)
+	}
+
+	done := make(chan dialResult, 1)
+	go func() {
+		client, err := p.dialResolved(ctx, dial, ip, port, timeout)
+		p.releaseDial()
+		done <- dialResult{client, err}
+	}()
+	select {
+	case r := <-done:
+		return p.opened(ip, port, r.client, r.err, timeout, connTimeout, overflow, hold, probe)
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-base.Done():
+		err = base.Err()
+	}
+	go p.adoptDial(done, ip, port, timeout, connTimeout, overflow, probe)
+	return nil, err
+}
+
+type dialResult struct {
+	client *IdleClient
+	err    error
+}
+
+// opened finishes a dial started by open. A dial cut short by its context
+// ending, as a happy eyeballs race is, is given up rather than counted as a
+// failure: its error is returned as is and the breaker does not see it.
+// There is no retry after it, so a held slot is released too.
+func (p *ThriftPool) opened(ip, port string, client *IdleClient, err error, timeout, connTimeout time.Duration, overflow, hold, probe bool) (*IdleClient, error) {
+	if isContextErr(err) {
+		p.lock.Lock()
+		p.releaseSlotLocked(overflow)
+		p.unreserveAddrLocked(net.JoinHostPort(ip, port))
+		p.probeAbandonedLocked(probe)
+		p.unlock()
+		return nil, err
+	}
+	if err != nil {
+		err = &DialError{Addr: net.JoinHostPort(ip, port), Err: err}
+	} else if !client.Check() {
+		err = ErrSocketDisconnect
+	}
+	// the handshake and priming still run under the dial's timeout
+	if err == nil && p.opts.onConnect != nil {
+		if err = p.opts.onConnect(client); err != nil {
+			p.closing(client, causeDialFailed)
+			p.closeClient(client)
+			err = &DialError{Addr: net.JoinHostPort(ip, port), Err: err}
+		}
+	}
+	if err == nil && p.opts.prime != nil {
+		if err = p.opts.prime(client); err != nil {
+			p.closing(client, causeDialFailed)
+			p.closeClient(client)
+			err = &DialError{Addr: net.JoinHostPort(ip, port), Err: err}
+		}
+	}
+	if err != nil {
+		p.lock.Lock()
+		if !hold {
+			p.releaseSlotLocked(overflow)
+		}
+		p.unreserveAddrLocked(net.JoinHostPort(ip, port))
+		p.breakerRecordLocked(false, p.now())
+		p.unlock()
+		p.stats.dialFailed(err)
+		p.emit(EventDialFailed, nil, err)
+		return nil, err
+	}
+	if p.opts.breakerThreshold > 0 {
+		p.lock.Lock()
+		p.breakerRecordLocked(true, p.now())
+		p.lock.Unlock()
+	}
+	if timeout != connTimeout && client.Socket != nil {
+		client.Socket.SetTimeout(connTimeout)
+	}
+	client.overflow = overflow
+	client.createdAt = p.now()
+	client.lastActive = client.createdAt
+	client.clock = p.now
+	client.home = p
+	client.target = net.JoinHostPort(ip, port)
+	// the address slot reserved by openSlot passes to the connection
+	client.tracked = p
+	p.stats.add(statDials)
+	p.emit(EventDialSucceeded, client, nil)
+	p.setState(client, StateNew)
+	return client, nil
+}
+
+// adoptDial waits for a dial whose caller gave up and pools the connection
+// in the slot reserved for it, or closes it if the pool has no room for it.
+func (p *ThriftPool) adoptDial(done <-chan dialResult, ip, port string, timeout, connTimeout time.Duration, overflow, probe bool) {
+	r := <-done
+	client, err := p.opened(ip, port, r.client, r.err, timeout, connTimeout, overflow, false, probe)
+	if err != nil {
+		return
+	}
+
+	// the connection is pooled only as a returned one would be
+	stale := p.staleTarget(client)
+	p.lock.Lock()
+	var lru *IdleEntry
+	admitted := false
+	cause := causeSurplus
+	switch {
+	case p.closed:
+		cause = causeShutdown
+	case overflow || p.rejectPut || stale:
+		cause = causeRetired
+	case p.overMaxLocked():
+	default:
+		lru, admitted = p.admitIdleLocked(p.now())
+	}
+	if !admitted {
+		p.releaseSlotLocked(overflow)
+		p.unlock()
+		p.closing(client, cause)
+		p.discard(client)
+		return
+	}
+	p.pushIdle(client)
+	p.lock.Unlock()
+	p.setState(client, StateIdle)
+	p.evictLRU(lru)
+}
+
+// reuse hands out an idle connection. It returns errDiscarded after closing
+// a connection that is past its lifetime or failed validation so the caller
+// can try another.
+func (p *ThriftPool) reuse(e *IdleEntry) (*IdleClient, error) {
+	c, since := e.Client, e.Since
+	p.freeIdleEntry(e)
+
+	if !c.Check() {
+		p.lock.Lock()
+		p.releaseSlotLocked(false)
+		p.unlock()
+		p.closing(c, causeReset)
+		p.discard(c)
+		return nil, ErrSocketDisconnect
+	}
+
+	now := p.now()
+	p.lock.Lock()
+	expired := p.lifetimeExpiredLocked(c, now)
+	validateAfter := p.opts.validateAfterIdle
+	if expired {
+		atomic.AddUint64(&p.stats.maxLifetimeClosed, 1)
+		p.releaseSlotLocked(false)
+		p.unlock()
+		p.closing(c, causeLifetime)
+		p.discard(c)
+		return nil, errDiscarded
+	}
+	p.lock.Unlock()
+
+	if p.opts.validate != nil && now.Sub(since) >= validateAfter {
+		if err := p.opts.validate(c); err != nil {
+			p.lock.Lock()
+			p.releaseSlotLocked(false)
+			p.unlock()
+			p.closing(c, causeValidation)
+			p.discard(c)
+			return nil, errDiscarded
+		}
+	}
+	p.borrow(c)
+	p.stats.add(statHits)
+	p.emit(EventReused, c, nil)
+	if fn := p.opts.onStaleReuse; fn != nil && now.Sub(since) > p.opts.staleReuseAfter {
+		fn(c, now.Sub(since))
+	}
+	client, err := p.onBorrow(c)
+	if err != nil {
+		return nil, errDiscarded
+	}
+	return client, nil
+}
+
+func (p *ThriftPool) lifetimeExpiredLocked(client *IdleClient, now time.Time) bool {
+	return p.opts.maxLifetime > 0 && now.Sub(client.createdAt) >= p.opts.maxLifetime &&
+		!p.holdIdleLocked(now)
+}
+
+// fullLocked reports whether opening another connection would exceed maxConn.
+func (p *ThriftPool) fullLocked() bool {
+	if p.opts.unlimited {
+		return false
+	}
+	if l := p.opts.limit; l != nil {
+		return atomic.LoadUint32(&l.count) >= p.maxConn
+	}
+	return p.count >= p.maxConn
+}
+
+func (p *ThriftPool) overMaxLocked() bool {
+	if p.opts.unlimited {
+		return false
+	}
+	if l := p.opts.limit; l != nil {
+		return atomic.LoadUint32(&l.count) > p.maxConn
+	}
+	return p.count > p.maxConn
+}
+
+// takeSlotLocked reserves a slot in p.count for a new connection once
+// fullLocked has said there is room. It only fails for a shard of a
+// ShardedPool whose last free slot another shard took meanwhile.
+func (p *ThriftPool) takeSlotLocked() bool {
+	if l := p.opts.limit; l != nil && !p.opts.unlimited && !l.take(p.maxConn) {
+		return false
+	}
+	p.count += 1
+	return true
+}
+
+func (p *ThriftPool) releaseSlotLocked(overflow bool) {
+	if p.count == 0 && p.overflow == 0 {
+		return
+	}
+	if overflow {
+		if p.overflow > 0 {
+			p.overflow -= 1
+		}
+	} else if p.count > 0 {
+		p.count -= 1
+		if l := p.opts.limit; l != nil && !p.opts.unlimited {
+			l.release()
 		}
-		return idlec.c, nil
+		p.signalWaiterLocked()
 	}
+	if p.count == 0 && p.overflow == 0 && p.hadConns {
+		p.hadConns = false
+		p.emptyPending = true
+	}
+}
+
+// unlock releases p.lock and then runs the notifications queued while it was
+// held, so hooks are free to call back into the pool.
+func (p *ThriftPool) unlock() {
+	empty := p.emptyPending
+	p.emptyPending = false
+	p.lock.Unlock()
+
+	if empty && p.opts.onEmpty != nil {
+		p.opts.onEmpty()
+	}
+}
+
+func (p *ThriftPool) closeClient(client *IdleClient) error {
+	untrack(client)
+	p.lock.Lock()
+	closeFunc := p.Close
+	p.lock.Unlock()
+	if closeFunc == nil {
+		closeFunc = defaultClose
+	}
+	err := closeFunc(client)
+	if err != nil {
+		p.publishCloseError(err)
+	}
+	p.setState(client, StateClosed)
+	return err
+}
+
+func defaultClose(c *IdleClient) error {
+	return c.Close()
+}
+
+func (p *ThriftPool) pushIdle(client *IdleClient) {
+	p.pushIdleEntry(p.newIdleEntry(client, p.now(), false))
+}
+
+func (p *ThriftPool) pushIdleEntry(e *IdleEntry) {
+	e.addr = e.Client.remoteAddr()
+	p.idle.Put(e)
+	p.hadConns = true
+	p.signalWaiterLocked()
+	if p.idleAdded != nil {
+		close(p.idleAdded)
+		p.idleAdded = nil
+	}
+}
+
+func (p *ThriftPool) takeIdleAddrLocked(addr string) *IdleEntry {
+	if s, ok := p.idle.(addrStore); ok {
+		return s.takeAddr(addr)
+	}
+	return p.idle.Take(func(e *IdleEntry) bool {
+		return e.addr == addr
+	})
+}
+
+func (p *ThriftPool) drainIdleLocked() []*IdleClient {
+	idle := make([]*IdleClient, 0, p.idle.Len())
+	for e := p.idle.Take(nil); e != nil; e = p.idle.Take(nil) {
+		idle = append(idle, e.Client)
+	}
+	return idle
 }
 
 func (p *ThriftPool) Put(client *IdleClient) error {
+	return p.PutResult(client, true)
+}
+
+// PutResult returns client like Put, recording whether the borrow went well.
+// A connection returned with ok false stays pooled but is evicted ahead of
+// healthy ones on the next reaper sweep.
+func (p *ThriftPool) PutResult(client *IdleClient, ok bool) error {
+	return p.put(client, ok, nil)
+}
+
+// put returns client to the pool. If info is non-nil it is filled in with
+// where the connection landed.
+func (p *ThriftPool) put(client *IdleClient, ok bool, info *PutInfo) error {
 	if client == nil {
 		return ErrInvalidConn
 	}
+	client, err := p.onReturn(client)
+	if err != nil {
+		p.closeErrConn(client)
+		return err
+	}
+	if !ok {
+		atomic.AddUint32(&client.errs, 1)
+	}
+	if h := client.home; h != nil && h != p {
+		// a tenant's connection goes back to its sub-pool
+		return h.putReturned(client, ok, info)
+	}
+	return p.putReturned(client, ok, info)
+}
+
+// putReturned is put after the OnReturn hook.
+func (p *ThriftPool) putReturned(client *IdleClient, ok bool, info *PutInfo) error {
+	return p.putChecked(client, ok, info, p.opts.validateOnPut && p.opts.validate != nil)
+}
+
+// putChecked is putReturned, running the WithValidateOnPut check if validate
+// is set.
+func (p *ThriftPool) putChecked(client *IdleClient, ok bool, info *PutInfo, validate bool) error {
+	stale := p.staleTarget(client)
 
 	p.lock.Lock()
-	if p.closed {
+	if client.revoked {
 		p.lock.Unlock()
+		return nil
+	}
+	delete(p.borrowed, client)
+	if client.deadlineSet {
+		client.Socket.SetTimeout(p.connTimeout)
+		client.deadlineSet = false
+	}
+
+	if p.closed {
+		p.releaseSlotLocked(client.overflow)
+		p.unlock()
+		p.closing(client, causeShutdown)
 
-		err := p.Close(client)
+		err := p.discard(client)
 		client = nil
 		return err
 	}
 
-	if p.count > p.maxConn {
-		if p.count > 0 {
-			p.count -= 1
-		}
-		p.lock.Unlock()
+	if client.overflow || client.retire || p.rejectPut {
+		p.releaseSlotLocked(client.overflow)
+		p.unlock()
+		p.closing(client, causeRetired)
 
-		err := p.Close(client)
+		err := p.discard(client)
 		client = nil
 		return err
 	}
 
-	if !client.Check() {
-		if p.count > 0 {
-			p.count -= 1
+	if p.overMaxLocked() {
+		p.releaseSlotLocked(false)
+		p.unlock()
+		p.closing(client, causeSurplus)
+
+		err := p.discard(client)
+		client = nil
+		return err
+	}
+
+	if !client.Check() || stale {
+		p.releaseSlotLocked(false)
+		p.unlock()
+		if stale {
+			p.closing(client, causeRetired)
+		} else {
+			p.closing(client, causeReset)
 		}
-		p.lock.Unlock()
 
-		err := p.Close(client)
+		err := p.discard(client)
 		client = nil
 		return err
 	}
 
-	p.idle.PushBack(&idleConn{
-		c: client,
-		t: nowFunc(),
-	})
-	p.lock.Unlock()
+	if max := p.opts.maxConnErrors; max > 0 && atomic.LoadUint32(&client.errs) >= max {
+		p.releaseSlotLocked(false)
+		p.unlock()
+		p.closing(client, causeCallerError)
+
+		err := p.discard(client)
+		client = nil
+		return err
+	}
+
+	if p.opts.maxUsage > 0 && client.usage >= p.opts.maxUsage {
+		p.releaseSlotLocked(false)
+		p.unlock()
+		p.closing(client, causeMaxUsage)
+
+		err := p.discard(client)
+		client = nil
+		return err
+	}
+
+	if p.lifetimeExpiredLocked(client, p.now()) {
+		atomic.AddUint64(&p.stats.maxLifetimeClosed, 1)
+		p.releaseSlotLocked(false)
+		p.unlock()
+		p.closing(client, causeLifetime)
+
+		err := p.discard(client)
+		client = nil
+		return err
+	}
+
+	if validate {
+		p.lock.Unlock()
+		return p.validateReturned(client, ok, info)
+	}
+
+	since := p.now()
+	if p.opts.idleFromActivity && !client.lastActive.IsZero() && client.lastActive.Before(since) {
+		since = client.lastActive
+	}
+	lru, admitted := p.admitIdleLocked(since)
+	if !admitted {
+		p.releaseSlotLocked(false)
+		p.unlock()
+		p.closing(client, causeSurplus)
 
+		err := p.discard(client)
+		client = nil
+		return err
+	}
+
+	e := p.newIdleEntry(client, since, !ok)
+	p.pushIdleEntry(e)
+	if info != nil {
+		info.fillLocked(p, e)
+	}
+	p.unlock()
+	p.emit(EventReturned, client, nil)
+	p.setState(client, StateIdle)
+	p.evictLRU(lru)
 	return nil
 }
 
+// admitIdleLocked applies the WithMaxIdle and WithIdleLRU caps to a
+// connection about to go idle since since. It reports false if the connection
+// should be closed instead. To make room it may take out the least recently
+// used entry, releasing its slot, for the caller to pass to evictLRU once
+// unlocked.
+func (p *ThriftPool) admitIdleLocked(since time.Time) (*IdleEntry, bool) {
+	if p.opts.maxIdle > 0 && uint32(p.idle.Len()) >= p.opts.maxIdle {
+		atomic.AddUint64(&p.stats.maxIdleClosed, 1)
+		return nil, false
+	}
+	if p.opts.idleLRU == 0 || p.idle.Len() < p.opts.idleLRU {
+		return nil, true
+	}
+	lru := p.lruLocked()
+	if lru == nil || !lru.Since.Before(since) {
+		// the connection is the least recently used itself
+		return nil, false
+	}
+	p.idle.Remove(lru)
+	p.releaseSlotLocked(false)
+	return lru, true
+}
+
+// evictLRU closes an entry admitIdleLocked took out, if any.
+func (p *ThriftPool) evictLRU(lru *IdleEntry) {
+	if lru == nil {
+		return
+	}
+	atomic.AddUint64(&p.stats.evictions, 1)
+	p.closing(lru.Client, causeSurplus)
+	p.emit(EventEvicted, lru.Client, nil)
+	p.discard(lru.Client)
+}
+
+// lruLocked returns the idle entry that has been idle longest.
+func (p *ThriftPool) lruLocked() *IdleEntry {
+	var lru *IdleEntry
+	p.idle.Each(func(e *IdleEntry) bool {
+		if lru == nil || e.Since.Before(lru.Since) {
+			lru = e
+		}
+		return true
+	})
+	return lru
+}
+
 func (p *ThriftPool) CloseErrConn(client *IdleClient) {
 	if client == nil {
 		return
 	}
+	client, _ = p.onReturn(client)
+	p.closeErrConn(client)
+}
 
+func (p *ThriftPool) closeErrConn(client *IdleClient) {
+	if h := client.home; h != nil && h != p {
+		h.closeErrConn(client)
+		return
+	}
 	p.lock.Lock()
-	if p.count > 0 {
-		p.count -= 1
+	if client.revoked {
+		p.lock.Unlock()
+		return
 	}
-	p.lock.Unlock()
+	delete(p.borrowed, client)
+	p.releaseSlotLocked(client.overflow)
+	p.unlock()
 
-	p.Close(client)
+	p.closing(client, causeCallerError)
+	p.discard(client)
 	client = nil
 	return
 }
 
 func (p *ThriftPool) CheckTimeout() {
 	p.lock.Lock()
-	for p.idle.Len() != 0 {
-		ele := p.idle.Back()
-		if ele == nil {
-			break
+	now := p.now()
+	// connections past their lifetime, or dead with WithReapDeadConns, go
+	// regardless of minIdle, fillIdle replaces them; suspect ones go next,
+	// then those past their idle timeout
+	var stale, dead, suspect []*IdleEntry
+	p.idle.Each(func(e *IdleEntry) bool {
+		if p.lifetimeExpiredLocked(e.Client, now) {
+			stale = append(stale, e)
+		} else if p.opts.reapDead && !e.Client.Check() {
+			dead = append(dead, e)
+		} else if e.suspect {
+			suspect = append(suspect, e)
 		}
-		v := ele.Value.(*idleConn)
-		if v.t.Add(p.idleTimeout).After(nowFunc()) {
+		return true
+	})
+	for _, e := range stale {
+		p.idle.Remove(e)
+	}
+	for _, e := range dead {
+		p.idle.Remove(e)
+	}
+	expired := append(stale, dead...)
+	for _, e := range suspect {
+		if uint32(p.idle.Len()) <= p.minIdleLocked() {
 			break
 		}
+		p.idle.Remove(e)
+		expired = append(expired, e)
+	}
+	var timedOut []*IdleEntry
+	if !p.holdIdleLocked(now) {
+		timedOut = p.idle.Evict(now.Add(-p.idleTimeout), int(p.minIdleLocked()))
+	}
+	expired = append(expired, timedOut...)
+	atomic.AddUint64(&p.stats.maxLifetimeClosed, uint64(len(stale)))
+	atomic.AddUint64(&p.stats.maxIdleTimeClosed, uint64(len(timedOut)))
+	for range expired {
+		p.releaseSlotLocked(false)
+	}
+	p.unlock()
+	atomic.AddUint64(&p.stats.evictions, uint64(len(expired)))
 
-		//timeout && clear
-		p.idle.Remove(ele)
-		p.lock.Unlock()
-		p.Close(v.c) //close client connection
-		p.lock.Lock()
-		if p.count > 0 {
-			p.count -= 1
+	for _, e := range stale {
+		p.closing(e.Client, causeLifetime)
+	}
+	for _, e := range dead {
+		p.closing(e.Client, causeReset)
+	}
+	for _, e := range expired[len(stale)+len(dead):] {
+		if e.suspect {
+			p.closing(e.Client, causeCallerError)
+		} else {
+			p.closing(e.Client, causeIdleTimeout)
 		}
 	}
-	p.lock.Unlock()
+
+	//timeout && clear
+	for _, e := range expired {
+		p.emit(EventEvicted, e.Client, nil)
+		p.closeClient(e.Client) //close client connection
+	}
 
 	return
 }
 
+// PeekHealthy reports whether the idle connection Get would hand out next
+// passes Check. It doesn't borrow the connection or touch the network, and
+// returns false when nothing is idle.
+func (p *ThriftPool) PeekHealthy() bool {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	healthy := false
+	p.idle.Each(func(e *IdleEntry) bool {
+		healthy = e.Client.Check()
+		return false
+	})
+	return healthy
+}
+
+// IdleByAddr returns the number of idle connections per remote address.
+func (p *ThriftPool) IdleByAddr() map[string]uint32 {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return p.idleByAddrLocked()
+}
+
+func (p *ThriftPool) idleByAddrLocked() map[string]uint32 {
+	if s, ok := p.idle.(addrStore); ok {
+		return s.countByAddr()
+	}
+	byAddr := make(map[string]uint32)
+	p.idle.Each(func(e *IdleEntry) bool {
+		if e.addr != "" {
+			byAddr[e.addr] += 1
+		}
+		return true
+	})
+	return byAddr
+}
+
 func (p *ThriftPool) GetIdleCount() uint32 {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
 	return uint32(p.idle.Len())
 }
 
 func (p *ThriftPool) GetConnCount() uint32 {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
 	return p.count
 }
 
+// ClearConn runs the reaper until the pool is released.
 func (p *ThriftPool) ClearConn() {
+	p.lock.Lock()
+	stop := p.stop
+	p.lock.Unlock()
+
 	for {
-		p.CheckTimeout()
-		time.Sleep(CHECKINTERVAL * time.Second)
+		p.lock.Lock()
+		paused := p.paused
+		p.lock.Unlock()
+		if !paused {
+			p.CheckTimeout()
+		}
+		p.CheckBorrowTime()
+		if !paused {
+			p.sampleIdle()
+		}
+		if p.opts.rejectStale && p.opts.resolver != nil {
+			// notice a new address even when nothing is dialed
+			p.callResolver()
+		}
+		p.fillIdle()
+
+		p.lock.Lock()
+		interval := p.opts.checkInterval
+		p.lock.Unlock()
+		select {
+		case <-stop:
+			return
+		case <-p.wake:
+		case <-time.After(interval):
+		}
 	}
 }
 
-func (p *ThriftPool) Release() {
+// Release closes the idle connections, stops the reaper and rejects further
+// Gets. Connections still borrowed keep their slot until they are returned,
+// at which point they are closed. Idle connections are closed concurrently,
+// started in WithReleaseOrder order, and those still closing when the release timeout passes are abandoned; a
+// *ReleaseError reports any that failed or were abandoned. Calling Release
+// again before Recover does nothing. A Recover racing with Release waits for
+// it to finish.
+func (p *ThriftPool) Release() error {
+	p.lifecycle.Lock()
+	defer p.lifecycle.Unlock()
+
 	p.lock.Lock()
-	idle := p.idle
-	p.idle.Init()
+	if p.closed {
+		p.lock.Unlock()
+		return nil
+	}
+	idle := p.drainIdleLocked()
 	p.closed = true
-	p.count = 0
-	p.lock.Unlock()
+	close(p.stop)
+	p.broadcastWaitersLocked()
+	for range idle {
+		p.releaseSlotLocked(false)
+	}
+	p.unlock()
+	p.closeEvents()
+	p.stopPutValidation()
+	p.stopAsyncClose()
 
-	for iter := idle.Front(); iter != nil; iter = iter.Next() {
-		p.Close(iter.Value.(*idleConn).c)
+	p.sortForRelease(idle)
+	err := p.closeAll(idle)
+	p.closeCloseErrors()
+	if terr := p.releaseTenants(); err == nil {
+		err = terr
 	}
+	return err
 }
 
+// Recover reopens a released pool. Once it returns, Gets no longer fail with
+// ErrPoolClosed.
 func (p *ThriftPool) Recover() {
+	p.lifecycle.Lock()
+	defer p.lifecycle.Unlock()
+
 	p.lock.Lock()
 	if p.closed == true {
 		p.closed = false
+		p.stop = make(chan struct{})
+		go p.ClearConn()
+		p.startStatsLogging(p.stop)
+		p.openEvents()
+		p.openCloseErrors()
+		p.startAsyncClose()
+		p.startPutValidation()
 	}
 	p.lock.Unlock()
 }
+
+// RecoverAndWarmup is Recover followed by Warmup, so the reopened pool holds
+// minIdle connections again before the first Get.
+func (p *ThriftPool) RecoverAndWarmup() error {
+	p.Recover()
+	return p.Warmup()
+}
@@ -0,0 +1,513 @@
+package thriftpool_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lehaisonmath6/thriftpool"
+	"github.com/lehaisonmath6/thriftpool/thriftpooltest"
+)
+
+func TestOnEmptyFiresOncePerDrain(t *testing.T) {
+	d := &thriftpooltest.Dialer{}
+	var fired int32
+	p := thriftpool.NewThriftPool("127.0.0.1", "9090", 3, 1, 10, d.Dial, nil,
+		thriftpool.WithOnEmpty(func() { atomic.AddInt32(&fired, 1) }))
+
+	d.FailWith(errors.New("refused"))
+	for i := 0; i < 5; i++ {
+		if _, err := p.Get(); err == nil {
+			t.Fatal("Get succeeded with a failing dial")
+		}
+	}
+	if n := atomic.LoadInt32(&fired); n != 0 {
+		t.Fatalf("OnEmpty fired %d times for dials failing on an empty pool", n)
+	}
+
+	d.FailWith(nil)
+	a, _ := p.Get()
+	b, _ := p.Get()
+	p.CloseErrConn(a)
+	d.FailWith(errors.New("refused"))
+	p.Get()
+	p.CloseErrConn(b)
+	if n := atomic.LoadInt32(&fired); n != 1 {
+		t.Fatalf("OnEmpty fired %d times after the last connection closed, want 1", n)
+	}
+
+	d.FailWith(nil)
+	c, _ := p.Get()
+	p.Put(c)
+	p.Release()
+	if n := atomic.LoadInt32(&fired); n != 2 {
+		t.Fatalf("OnEmpty fired %d times after Release, want 2", n)
+	}
+}
+
+func TestEvictionFollowsIdleTimeNotOrder(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		store func() thriftpool.IdleStore
+	}{
+		{"fifo", thriftpool.NewFIFOStore},
+		{"lifo", thriftpool.NewLIFOStore},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			d := &thriftpooltest.Dialer{}
+			clk := thriftpooltest.NewClock(time.Unix(1000, 0))
+			p := thriftpool.NewThriftPool("127.0.0.1", "9090", 2, 1, 10, d.Dial, nil,
+				thriftpool.WithClock(clk.Now), thriftpool.WithIdleStore(tc.store),
+				thriftpool.WithOverflowPolicy(thriftpool.Block))
+			defer p.Release()
+
+			a, _ := p.Get()
+			b, _ := p.Get()
+			// a is handed straight to a waiting Get and comes back later,
+			// so it was idle for less time than b although returned first
+			got := make(chan *thriftpool.IdleClient)
+			go func() {
+				c, err := p.Get()
+				if err != nil {
+					t.Error(err)
+				}
+				got <- c
+			}()
+			for p.Stats().Waiters == 0 {
+				time.Sleep(time.Millisecond)
+			}
+			p.Put(a)
+			c := <-got
+			clk.Advance(5 * time.Second)
+			p.Put(b)
+			clk.Advance(3 * time.Second)
+			p.Put(c)
+
+			clk.Advance(6 * time.Second)
+			p.CheckTimeout()
+			if n := p.Stats().Idle; n != 2 {
+				t.Fatalf("%d idle before either timed out, want 2", n)
+			}
+			clk.Advance(2 * time.Second)
+			p.CheckTimeout()
+			if n := p.Stats().Idle; n != 1 {
+				t.Fatalf("%d idle after b timed out, want 1", n)
+			}
+			if b.Check() {
+				t.Error("b, idle longest, was not the one evicted")
+			}
+			if !c.Check() {
+				t.Error("connection returned last was evicted")
+			}
+		})
+	}
+}
+
+func TestNilCloseClosesSockets(t *testing.T) {
+	d := &thriftpooltest.Dialer{}
+	clk := thriftpooltest.NewClock(time.Unix(1000, 0))
+	p := thriftpool.NewThriftPool("127.0.0.1", "9090", 3, 1, 10, d.Dial, nil,
+		thriftpool.WithClock(clk.Now))
+
+	a, _ := p.Get()
+	b, _ := p.Get()
+	c, _ := p.Get()
+	p.CloseErrConn(a)
+	if a.Check() {
+		t.Error("CloseErrConn left the socket open")
+	}
+	p.Put(b)
+	clk.Advance(11 * time.Second)
+	p.CheckTimeout()
+	if b.Check() {
+		t.Error("eviction left the socket open")
+	}
+	if err := p.Release(); err != nil {
+		t.Fatal(err)
+	}
+	p.Put(c)
+	if n := d.Open(); n != 0 {
+		t.Fatalf("%d connections open after Release, want 0", n)
+	}
+
+	q, err := thriftpool.New(thriftpool.WithDial(d.Dial), thriftpool.WithMaxConn(1),
+		thriftpool.WithAddrs("127.0.0.1:9090"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, _ = q.Get()
+	q.Put(c)
+	q.Release()
+	if c.Check() {
+		t.Error("pool from New without WithClose left the socket open")
+	}
+}
+
+func TestUnlimitedNeverOverMax(t *testing.T) {
+	d := &thriftpooltest.Dialer{}
+	clk := thriftpooltest.NewClock(time.Unix(1000, 0))
+	p := thriftpool.NewThriftPool("127.0.0.1", "9090", 0, 1, 10, d.Dial, nil,
+		thriftpool.WithUnlimited(), thriftpool.WithClock(clk.Now))
+	defer p.Release()
+
+	const n = 200
+	var wg sync.WaitGroup
+	clients := make(chan *thriftpool.IdleClient, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c, err := p.Get()
+			if err != nil {
+				t.Errorf("Get: %v", err)
+				return
+			}
+			clients <- c
+		}()
+	}
+	wg.Wait()
+	close(clients)
+	if s := p.Stats(); s.Active != n {
+		t.Fatalf("%d active, want %d", s.Active, n)
+	}
+	for c := range clients {
+		p.Put(c)
+	}
+	if s := p.Stats(); s.Idle != n || s.Active != n {
+		t.Fatalf("after Put: %d idle, %d active, want %d each", s.Idle, s.Active, n)
+	}
+
+	clk.Advance(11 * time.Second)
+	p.CheckTimeout()
+	if s := p.Stats(); s.Idle != 0 || s.Active != 0 || d.Open() != 0 {
+		t.Fatalf("after reaping: %d idle, %d active, %d open", s.Idle, s.Active, d.Open())
+	}
+}
+
+func TestGetCancelledMidDial(t *testing.T) {
+	for _, release := range []bool{false, true} {
+		d := &thriftpooltest.Dialer{}
+		started, finish := make(chan struct{}), make(chan struct{})
+		dial := func(ip, port string, timeout time.Duration) (*thriftpool.IdleClient, error) {
+			close(started)
+			<-finish
+			return d.Dial(ip, port, timeout)
+		}
+		var closes int32
+		closeFn := func(c *thriftpool.IdleClient) error {
+			defer atomic.AddInt32(&closes, 1)
+			return c.Socket.Close()
+		}
+		p := thriftpool.NewThriftPool("127.0.0.1", "9090", 2, 1, 10, dial, closeFn)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			<-started
+			cancel()
+		}()
+		if _, err := p.GetContext(ctx); err != context.Canceled {
+			t.Fatalf("GetContext = %v, want context.Canceled", err)
+		}
+		if release {
+			p.Release()
+		}
+		close(finish)
+
+		// the late connection is pooled, or closed if the pool is gone
+		want := uint32(1)
+		if release {
+			want = 0
+		}
+		deadline := time.Now().Add(time.Second)
+		for p.GetConnCount() != want || p.Stats().Idle != want || atomic.LoadInt32(&closes) != 1-int32(want) {
+			if time.Now().After(deadline) {
+				t.Fatalf("release=%v: %d connections counted, %d idle", release, p.GetConnCount(), p.Stats().Idle)
+			}
+			time.Sleep(time.Millisecond)
+		}
+		if d.Dials() != 1 || d.Open() != int(want) {
+			t.Errorf("release=%v: %d dialed, %d open, want 1 and %d", release, d.Dials(), d.Open(), want)
+		}
+		p.Release()
+	}
+}
+
+func TestIdleLRUEvictionOrder(t *testing.T) {
+	d := &thriftpooltest.Dialer{}
+	clk := thriftpooltest.NewClock(time.Unix(1000, 0))
+	var mu sync.Mutex
+	var closed []*thriftpool.IdleClient
+	p := thriftpool.NewThriftPool("127.0.0.1", "9090", 5, 1, 60, d.Dial, nil,
+		thriftpool.WithClock(clk.Now), thriftpool.WithIdleLRU(2),
+		thriftpool.WithConnState(func(c *thriftpool.IdleClient, s thriftpool.ConnState) {
+			if s == thriftpool.StateClosed {
+				mu.Lock()
+				closed = append(closed, c)
+				mu.Unlock()
+			}
+		}))
+	defer p.Release()
+
+	var cs []*thriftpool.IdleClient
+	for i := 0; i < 4; i++ {
+		c, _ := p.Get()
+		cs = append(cs, c)
+	}
+	put := func(c *thriftpool.IdleClient) {
+		clk.Advance(time.Second)
+		p.Put(c)
+	}
+	put(cs[0])
+	put(cs[1])
+	put(cs[2]) // evicts cs[0]
+	// reusing cs[1] makes cs[2] the least recently used
+	c, _ := p.Get()
+	if c != cs[1] {
+		t.Fatal("Get did not hand out the longest idle connection")
+	}
+	put(c)
+	put(cs[3]) // evicts cs[2]
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(closed) != 2 || closed[0] != cs[0] || closed[1] != cs[2] {
+		t.Fatalf("evicted %v, want cs[0] then cs[2]", closed)
+	}
+	if s := p.Stats(); s.Idle != 2 {
+		t.Errorf("%d idle, want 2", s.Idle)
+	}
+	if !cs[1].Check() || !cs[3].Check() {
+		t.Error("a recently used connection was closed")
+	}
+}
+
+func TestGetAfterRecoverNeverClosed(t *testing.T) {
+	d := &thriftpooltest.Dialer{}
+	p := thriftpool.NewThriftPool("127.0.0.1", "9090", 4, 1, 10, d.Dial, nil,
+		thriftpool.WithMinIdle(2), thriftpool.WithOverflowPolicy(thriftpool.Block))
+	defer p.Release()
+
+	for round := 0; round < 20; round++ {
+		p.Release()
+		var recovered int32
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 20; j++ {
+					after := atomic.LoadInt32(&recovered) == 1
+					c, err := p.Get()
+					if err == thriftpool.ErrPoolClosed {
+						if after {
+							t.Error("Get started after Recover returned failed with ErrPoolClosed")
+						}
+						continue
+					}
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					p.Put(c)
+				}
+			}()
+		}
+		p.Recover()
+		atomic.StoreInt32(&recovered, 1)
+		wg.Wait()
+	}
+
+	p.Release()
+	if err := p.RecoverAndWarmup(); err != nil {
+		t.Fatal(err)
+	}
+	if s := p.Stats(); s.Idle < 2 {
+		t.Errorf("%d idle after RecoverAndWarmup, want minIdle 2", s.Idle)
+	}
+}
+
+func TestDeadlinePropagationPastDeadline(t *testing.T) {
+	d := &thriftpooltest.Dialer{}
+	var slow int32
+	p := thriftpool.NewThriftPool("127.0.0.1", "9090", 1, 1, 10, d.Dial, nil,
+		thriftpool.WithDeadlinePropagation(),
+		thriftpool.WithOnBorrow(func(c *thriftpool.IdleClient) (*thriftpool.IdleClient, error) {
+			if atomic.LoadInt32(&slow) == 1 {
+				time.Sleep(20 * time.Millisecond)
+			}
+			return c, nil
+		}))
+	defer p.Release()
+	c, _ := p.Get()
+	p.Put(c)
+
+	// the deadline passes while the idle connection is being handed out
+	atomic.StoreInt32(&slow, 1)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	if _, err := p.GetContext(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("GetContext past its deadline = %v, want context.DeadlineExceeded", err)
+	}
+	if s := p.Stats(); s.Idle != 1 || s.Active != 1 {
+		t.Errorf("%d idle, %d active, want the connection back in the pool", s.Idle, s.Active)
+	}
+}
+
+func TestRejectStaleAddrDoesNotResolveOnPut(t *testing.T) {
+	d := &thriftpooltest.Dialer{}
+	var mu sync.Mutex
+	calls, ip := 0, "10.0.0.1"
+	resolver := func() (string, string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		return ip, "9090", nil
+	}
+	p := thriftpool.NewThriftPool("backend", "9090", 2, 1, 10, d.Dial, nil,
+		thriftpool.WithResolver(resolver), thriftpool.WithRejectStaleAddr(),
+		thriftpool.WithCheckInterval(time.Hour))
+	defer p.Release()
+
+	old, _ := p.Get()
+	p.Put(old)
+	// one call for the dial, one for the reaper's first tick
+	for {
+		mu.Lock()
+		n := calls
+		mu.Unlock()
+		if n >= 2 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	mu.Lock()
+	before := calls
+	mu.Unlock()
+	for i := 0; i < 50; i++ {
+		c, _ := p.Get()
+		p.Put(c)
+	}
+	mu.Lock()
+	if calls != before {
+		t.Errorf("50 Puts called the resolver %d times", calls-before)
+	}
+	ip = "10.0.0.2"
+	mu.Unlock()
+
+	// the next dial learns the new address, and the old connection is
+	// dropped when it comes back
+	c, _ := p.Get()
+	fresh, _ := p.Get()
+	p.Put(c)
+	p.Put(fresh)
+	if c.Check() {
+		t.Error("connection to the old address was pooled")
+	}
+	if !fresh.Check() {
+		t.Error("connection to the current address was closed")
+	}
+	if s := p.Stats(); s.Idle != 1 {
+		t.Errorf("%d idle, want 1", s.Idle)
+	}
+}
+
+func TestIdleSamplingKeepsMaxIdle(t *testing.T) {
+	d := &thriftpooltest.Dialer{}
+	var blocking int32
+	sampling := make(chan struct{}, 4)
+	gate := make(chan struct{})
+	validate := func(c *thriftpool.IdleClient) error {
+		if atomic.LoadInt32(&blocking) == 1 {
+			sampling <- struct{}{}
+			<-gate
+		}
+		return nil
+	}
+	p := thriftpool.NewThriftPool("127.0.0.1", "9090", 4, 1, 10, d.Dial, nil,
+		thriftpool.WithMaxIdle(2), thriftpool.WithValidate(validate),
+		thriftpool.WithIdleSampling(1), thriftpool.WithCheckInterval(20*time.Millisecond))
+	defer p.Release()
+
+	var cs []*thriftpool.IdleClient
+	for i := 0; i < 4; i++ {
+		c, err := p.Get()
+		if err != nil {
+			t.Fatal(err)
+		}
+		cs = append(cs, c)
+	}
+	p.Put(cs[0])
+	p.Put(cs[1])
+	atomic.StoreInt32(&blocking, 1)
+	<-sampling
+
+	// fill the idle list while the sample is out being validated
+	p.Put(cs[2])
+	p.Put(cs[3])
+	atomic.StoreInt32(&blocking, 0)
+	close(gate)
+	for deadline := time.Now().Add(time.Second); p.GetConnCount() > 2 && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	if n := p.Stats().Idle; n != 2 {
+		t.Errorf("%d idle after the sample went back, want WithMaxIdle 2", n)
+	}
+}
+
+func TestGetCancelledMidDialKeepsIdleLRU(t *testing.T) {
+	d := &thriftpooltest.Dialer{}
+	var dials int32
+	started, finish := make(chan struct{}), make(chan struct{})
+	dial := func(ip, port string, timeout time.Duration) (*thriftpool.IdleClient, error) {
+		if atomic.AddInt32(&dials, 1) == 3 {
+			close(started)
+			<-finish
+		}
+		return d.Dial(ip, port, timeout)
+	}
+	var closes int32
+	closeFn := func(c *thriftpool.IdleClient) error {
+		defer atomic.AddInt32(&closes, 1)
+		return c.Socket.Close()
+	}
+	p := thriftpool.NewThriftPool("127.0.0.1", "9090", 3, 1, 10, dial, closeFn,
+		thriftpool.WithIdleLRU(1))
+	defer p.Release()
+
+	var cs []*thriftpool.IdleClient
+	for i := 0; i < 2; i++ {
+		c, err := p.Get()
+		if err != nil {
+			t.Fatal(err)
+		}
+		cs = append(cs, c)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+	if _, err := p.GetContext(ctx); err != context.Canceled {
+		t.Fatalf("GetContext = %v, want context.Canceled", err)
+	}
+	// fill the idle list to its cap before the late connection arrives
+	for _, c := range cs {
+		p.Put(c)
+	}
+	close(finish)
+
+	// the late connection pushes out the least recently used one
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt32(&closes) != 2 || p.GetConnCount() != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d closed, %d connections counted, want 2 and 1", atomic.LoadInt32(&closes), p.GetConnCount())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if n := p.Stats().Idle; n != 1 {
+		t.Errorf("%d idle, want WithIdleLRU 1", n)
+	}
+}
@@ -0,0 +1,197 @@
+// Package thriftpooltest provides in-memory connections and a manual clock for
+// exercising a thriftpool.ThriftPool without a Thrift server.
+package thriftpooltest
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/apache/thrift/lib/go/thrift"
+	"github.com/lehaisonmath6/thriftpool"
+)
+
+var ErrConnClosed = errors.New("ErrConnClosed")
+
+type addr string
+
+func (a addr) Network() string { return "fake" }
+func (a addr) String() string  { return string(a) }
+
+// Conn is a net.Conn that discards writes and never has anything to read.
+type Conn struct {
+	local, remote net.Addr
+
+	mu     sync.Mutex
+	closed bool
+}
+
+func NewConn(remote string) *Conn {
+	return &Conn{local: addr("fake:0"), remote: addr(remote)}
+}
+
+func (c *Conn) Read(b []byte) (int, error) {
+	if c.Closed() {
+		return 0, ErrConnClosed
+	}
+	return 0, nil
+}
+
+func (c *Conn) Write(b []byte) (int, error) {
+	if c.Closed() {
+		return 0, ErrConnClosed
+	}
+	return len(b), nil
+}
+
+func (c *Conn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return ErrConnClosed
+	}
+	c.closed = true
+	return nil
+}
+
+func (c *Conn) Closed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.closed
+}
+
+func (c *Conn) LocalAddr() net.Addr                { return c.local }
+func (c *Conn) RemoteAddr() net.Addr               { return c.remote }
+func (c *Conn) SetDeadline(t time.Time) error      { return nil }
+func (c *Conn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *Conn) SetWriteDeadline(t time.Time) error { return nil }
+
+// NewClient returns an open IdleClient backed by a Conn to remote.
+func NewClient(remote string, timeout time.Duration) *thriftpool.IdleClient {
+	socket := thrift.NewTSocketFromConnTimeout(NewConn(remote), timeout)
+	return &thriftpool.IdleClient{
+		Socket: socket,
+		Client: socket,
+	}
+}
+
+// FakeDial is a thriftpool.ThriftDial that always succeeds with a NewClient.
+func FakeDial(ip, port string, connTimeout time.Duration) (*thriftpool.IdleClient, error) {
+	return NewClient(net.JoinHostPort(ip, port), connTimeout), nil
+}
+
+// FakeClose is a thriftpool.ThriftClientClose for clients made by this package.
+func FakeClose(c *thriftpool.IdleClient) error {
+	return c.Socket.Close()
+}
+
+// Break closes c's socket behind the pool's back so that Check fails, as if
+// the server had dropped the connection.
+func Break(c *thriftpool.IdleClient) {
+	c.Socket.Close()
+}
+
+// Dialer is a controllable thriftpool.ThriftDial that records what it dialed.
+type Dialer struct {
+	mu      sync.Mutex
+	err     error
+	clients []*thriftpool.IdleClient
+}
+
+// Dial is the dial function to hand to the pool.
+func (d *Dialer) Dial(ip, port string, connTimeout time.Duration) (*thriftpool.IdleClient, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.err != nil {
+		return nil, d.err
+	}
+	c := NewClient(net.JoinHostPort(ip, port), connTimeout)
+	d.clients = append(d.clients, c)
+	return c, nil
+}
+
+// FailWith makes later dials fail with err, or succeed again if err is nil.
+func (d *Dialer) FailWith(err error) {
+	d.mu.Lock()
+	d.err = err
+	d.mu.Unlock()
+}
+
+// Dials returns how many connections have been dialed successfully.
+func (d *Dialer) Dials() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.clients)
+}
+
+// Open returns how many dialed connections have not been closed.
+func (d *Dialer) Open() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	n := 0
+	for _, c := range d.clients {
+		if c.Socket.IsOpen() {
+			n++
+		}
+	}
+	return n
+}
+
+// Clock is a manual clock for thriftpool.WithClock.
+type Clock struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+func NewClock(start time.Time) *Clock {
+	return &Clock{t: start}
+}
+
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t
+}
+
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.t = c.t.Add(d)
+	c.mu.Unlock()
+}
+
+// DialStep is one scripted dial: it takes Delay, then fails with Err or,
+// with a nil Err, succeeds with a NewClient.
+type DialStep struct {
+	Delay time.Duration
+	Err   error
+}
+
+// ScriptedDial returns a thriftpool.ThriftDial that plays steps in order,
+// one per dial, then succeeds at once once they run out. Each dial claims
+// its step as it starts, so concurrent Gets consume the script in the order
+// they dial. A step whose Delay exceeds the dial's timeout gives up after the
+// timeout with a thrift TIMED_OUT error, as a black-holed address would.
+func ScriptedDial(steps []DialStep) thriftpool.ThriftDial {
+	var mu sync.Mutex
+	next := 0
+	return func(ip, port string, connTimeout time.Duration) (*thriftpool.IdleClient, error) {
+		var step DialStep
+		mu.Lock()
+		if next < len(steps) {
+			step = steps[next]
+			next++
+		}
+		mu.Unlock()
+
+		if connTimeout > 0 && step.Delay > connTimeout {
+			time.Sleep(connTimeout)
+			return nil, thrift.NewTTransportException(thrift.TIMED_OUT, "thriftpooltest: scripted dial timed out")
+		}
+		time.Sleep(step.Delay)
+		if step.Err != nil {
+			return nil, step.Err
+		}
+		return NewClient(net.JoinHostPort(ip, port), connTimeout), nil
+	}
+}
@@ -0,0 +1,95 @@
+package thriftpool
+
+import (
+	"container/list"
+	"time"
+)
+
+type tieredStore struct {
+	hotSize  int
+	coldSize int
+	hot      list.List
+	cold     list.List
+}
+
+// NewTieredStore returns a store with a hot tier of up to hotSize recently
+// returned connections, which Get uses first, and a cold tier below it that
+// is only drawn on when the hot tier is empty. Connections pushed out of the
+// hot tier drop into the cold one. Eviction takes cold connections first, and
+// cold connections beyond coldSize are evicted on the next sweep even if
+// they aren't past the idle timeout; a coldSize of zero leaves the cold tier
+// unbounded. This keeps the steady-state working set warm while surplus
+// connections are reclaimed.
+func NewTieredStore(hotSize, coldSize int) IdleStore {
+	return &tieredStore{hotSize: hotSize, coldSize: coldSize}
+}
+
+func (s *tieredStore) Len() int {
+	return s.hot.Len() + s.cold.Len()
+}
+
+func (s *tieredStore) Put(e *IdleEntry) {
+	e.cold = false
+	e.ele = s.hot.PushBack(e)
+	for s.hot.Len() > s.hotSize {
+		demoted := s.hot.Remove(s.hot.Front()).(*IdleEntry)
+		demoted.cold = true
+		demoted.ele = s.cold.PushBack(demoted)
+	}
+}
+
+func (s *tieredStore) Take(pred func(*IdleEntry) bool) *IdleEntry {
+	var taken *IdleEntry
+	s.Each(func(e *IdleEntry) bool {
+		if pred == nil || pred(e) {
+			taken = e
+			return false
+		}
+		return true
+	})
+	if taken != nil {
+		s.Remove(taken)
+	}
+	return taken
+}
+
+func (s *tieredStore) Remove(e *IdleEntry) bool {
+	if e.ele == nil {
+		return false
+	}
+	if e.cold {
+		s.cold.Remove(e.ele)
+	} else {
+		s.hot.Remove(e.ele)
+	}
+	e.ele = nil
+	return true
+}
+
+func (s *tieredStore) Evict(deadline time.Time, keep int) []*IdleEntry {
+	var evicted []*IdleEntry
+	for _, l := range []*list.List{&s.cold, &s.hot} {
+		for ele := l.Front(); ele != nil && s.Len() > keep; {
+			next := ele.Next()
+			e := ele.Value.(*IdleEntry)
+			surplus := e.cold && s.coldSize > 0 && s.cold.Len() > s.coldSize
+			if surplus || !e.Since.After(deadline) {
+				s.Remove(e)
+				evicted = append(evicted, e)
+			}
+			ele = next
+		}
+	}
+	return evicted
+}
+
+// Each visits the hot tier most recent first, then the cold tier the same way.
+func (s *tieredStore) Each(fn func(*IdleEntry) bool) {
+	for _, l := range []*list.List{&s.hot, &s.cold} {
+		for ele := l.Back(); ele != nil; ele = ele.Prev() {
+			if !fn(ele.Value.(*IdleEntry)) {
+				return
+			}
+		}
+	}
+}
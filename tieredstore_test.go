@@ -0,0 +1,63 @@
+package thriftpool_test
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/lehaisonmath6/thriftpool"
+	"github.com/lehaisonmath6/thriftpool/thriftpooltest"
+)
+
+// churn runs a steady load of 2 to 4 concurrent calls a second, with a burst
+// of 12 every 10 seconds, and returns how many connections were dialed and
+// the average number left idle after each sweep.
+func churn(t *testing.T, idleTimeout uint32, opts ...thriftpool.Option) (int, float64) {
+	d := &thriftpooltest.Dialer{}
+	clk := thriftpooltest.NewClock(time.Unix(1000, 0))
+	opts = append(opts, thriftpool.WithClock(clk.Now), thriftpool.WithCheckInterval(time.Hour))
+	p := thriftpool.NewThriftPool("127.0.0.1", "9090", 50, 1, idleTimeout, d.Dial, nil, opts...)
+	defer p.Release()
+
+	r := rand.New(rand.NewSource(1))
+	const ticks = 500
+	idle := 0
+	for tick := 0; tick < ticks; tick++ {
+		n := 2 + r.Intn(3)
+		if tick%10 == 0 {
+			n = 12
+		}
+		var cs []*thriftpool.IdleClient
+		for i := 0; i < n; i++ {
+			c, err := p.Get()
+			if err != nil {
+				t.Fatal(err)
+			}
+			cs = append(cs, c)
+		}
+		for _, c := range cs {
+			p.Put(c)
+		}
+		clk.Advance(time.Second)
+		p.CheckTimeout()
+		idle += int(p.Stats().Idle)
+	}
+	return d.Dials(), float64(idle) / ticks
+}
+
+func TestTieredIdleReducesChurn(t *testing.T) {
+	// the single tier needs a short idle timeout to reclaim the burst's
+	// surplus; the tiered pool bounds it by count and keeps its working set
+	singleDials, singleIdle := churn(t, 3)
+	tieredDials, tieredIdle := churn(t, 60, thriftpool.WithTieredIdle(3, 3))
+	t.Logf("single tier: %d dials, %.1f idle; tiered: %d dials, %.1f idle",
+		singleDials, singleIdle, tieredDials, tieredIdle)
+	if tieredIdle > singleIdle {
+		t.Errorf("tiered pool kept %.1f connections idle on average, more than the single tier's %.1f",
+			tieredIdle, singleIdle)
+	}
+	if tieredDials >= singleDials {
+		t.Errorf("tiered pool dialed %d connections, no fewer than the single tier's %d",
+			tieredDials, singleDials)
+	}
+}
@@ -0,0 +1,55 @@
+package thriftpool
+
+// BorrowToken identifies a connection handed out by GetTracked, so that a
+// later stage of the same operation can ask for it again with GetByToken.
+// The zero BorrowToken matches nothing.
+type BorrowToken struct {
+	client *IdleClient
+}
+
+// GetTracked is Get, also returning a token for the connection.
+func (p *ThriftPool) GetTracked() (*IdleClient, BorrowToken, error) {
+	client, err := p.Get()
+	if err != nil {
+		return nil, BorrowToken{}, err
+	}
+	return client, BorrowToken{client: client}, nil
+}
+
+// GetByToken borrows the connection token names if it is still idle. It
+// returns false if the connection was taken by someone else, evicted or fails
+// validation, and the caller should fall back to Get.
+func (p *ThriftPool) GetByToken(token BorrowToken) (*IdleClient, bool) {
+	if token.client == nil {
+		return nil, false
+	}
+	if home := token.client.home; home != nil && home != p {
+		return home.GetByToken(token)
+	}
+
+	p.lock.Lock()
+	if p.closed {
+		p.lock.Unlock()
+		return nil, false
+	}
+	var found *IdleEntry
+	p.idle.Each(func(e *IdleEntry) bool {
+		if e.Client == token.client {
+			found = e
+			return false
+		}
+		return true
+	})
+	if found == nil || !p.idle.Remove(found) {
+		p.lock.Unlock()
+		return nil, false
+	}
+	p.lock.Unlock()
+
+	p.stats.add(statGets)
+	client, err := p.reuse(found)
+	if err != nil {
+		return nil, false
+	}
+	return client, true
+}
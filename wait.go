@@ -0,0 +1,201 @@
+package thriftpool
+
+import (
+	"container/list"
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+func (p *ThriftPool) baseContext() context.Context {
+	if p.opts.baseCtx != nil {
+		return p.opts.baseCtx
+	}
+	return context.Background()
+}
+
+func (p *ThriftPool) ctxErr(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return p.baseContext().Err()
+}
+
+// dialTimeout caps limit by the time left on ctx and the base context.
+func (p *ThriftPool) dialTimeout(ctx context.Context, limit time.Duration) (time.Duration, error) {
+	if err := p.ctxErr(ctx); err != nil {
+		return 0, err
+	}
+	timeout := limit
+	for _, c := range []context.Context{ctx, p.baseContext()} {
+		deadline, ok := c.Deadline()
+		if !ok {
+			continue
+		}
+		left := time.Until(deadline)
+		if left <= 0 {
+			return 0, context.DeadlineExceeded
+		}
+		if timeout == 0 || left < timeout {
+			timeout = left
+		}
+	}
+	return timeout, nil
+}
+
+// addWaiterLocked queues a Get waiting for a slot or an idle connection.
+// Pools sharing a slotLimit queue on it instead, so that a slot freed in one
+// wakes waiters in the others.
+func (p *ThriftPool) addWaiterLocked() (chan struct{}, *list.Element) {
+	if l := p.opts.limit; l != nil {
+		return l.addWaiter()
+	}
+	ch := make(chan struct{}, 1)
+	return ch, p.waiters.PushBack(ch)
+}
+
+// waitersLocked counts the queued Gets, across the pools sharing a slotLimit.
+func (p *ThriftPool) waitersLocked() int {
+	if l := p.opts.limit; l != nil {
+		return int(atomic.LoadInt32(&l.waiting))
+	}
+	return p.waiters.Len()
+}
+
+// signalWaiterLocked wakes the longest waiting Get, if any, to retry.
+func (p *ThriftPool) signalWaiterLocked() {
+	if ele := p.waiters.Front(); ele != nil {
+		p.waiters.Remove(ele)
+		ele.Value.(chan struct{}) <- struct{}{}
+		return
+	}
+	if l := p.opts.limit; l != nil {
+		l.signal()
+	}
+}
+
+func (p *ThriftPool) broadcastWaitersLocked() {
+	for p.waiters.Len() > 0 {
+		p.signalWaiterLocked()
+	}
+	if l := p.opts.limit; l != nil {
+		l.broadcast()
+	}
+}
+
+// wait is awaitWake for a Get, counted in the wait stats.
+func (p *ThriftPool) wait(ctx context.Context, ch chan struct{}, ele *list.Element) error {
+	start := time.Now()
+	atomic.AddUint64(&p.stats.waitCount, 1)
+	p.stats.window.add(statWaits, p.now())
+	defer func() {
+		atomic.AddUint64(&p.stats.waitDuration, uint64(time.Since(start)))
+	}()
+
+	err := p.awaitWake(ctx, ch, ele)
+	if err == context.DeadlineExceeded {
+		p.stats.add(statTimeouts)
+	}
+	return err
+}
+
+// awaitWake blocks on a waiter queued by addWaiterLocked until it is woken or
+// ctx or the base context is done, dequeuing it in the latter case.
+func (p *ThriftPool) awaitWake(ctx context.Context, ch chan struct{}, ele *list.Element) error {
+	var err error
+	base := p.baseContext()
+	select {
+	case <-ch:
+		return nil
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-base.Done():
+		err = base.Err()
+	}
+
+	if l := p.opts.limit; l != nil {
+		l.cancel(ch, ele)
+	} else {
+		p.lock.Lock()
+		select {
+		case <-ch:
+			// woken while giving up, pass the wakeup on
+			p.signalWaiterLocked()
+		default:
+			p.waiters.Remove(ele)
+		}
+		p.lock.Unlock()
+	}
+	return err
+}
+
+// acquireDial takes one of the WithMaxConcurrentDials slots, waiting for one
+// to free up until ctx or the base context is done.
+func (p *ThriftPool) acquireDial(ctx context.Context) error {
+	if p.dialSem == nil {
+		return nil
+	}
+	select {
+	case p.dialSem <- struct{}{}:
+		return nil
+	default:
+	}
+
+	base := p.baseContext()
+	select {
+	case p.dialSem <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-base.Done():
+		return base.Err()
+	}
+}
+
+func (p *ThriftPool) releaseDial() {
+	if p.dialSem != nil {
+		<-p.dialSem
+	}
+}
+
+// WaitForCapacity blocks until a Get could be served without waiting: a
+// connection is idle or there is room to dial one. It acquires nothing, so
+// the capacity may be gone again by the time the caller Gets; it is meant
+// for admitting work, not reserving for it. It queues with the waiting Gets,
+// and like them fails with ErrTooManyWaiters once WithMaxWaiters are queued,
+// but isn't counted in the wait stats. It fails with ErrPoolClosed on a
+// released pool, or when ctx or the base context is done.
+func (p *ThriftPool) WaitForCapacity(ctx context.Context) error {
+	woken := false
+	for {
+		if err := p.ctxErr(ctx); err != nil {
+			return err
+		}
+		p.lock.Lock()
+		if p.closed {
+			p.lock.Unlock()
+			return ErrPoolClosed
+		}
+		if p.idle.Len() > 0 || !p.fullLocked() {
+			if woken {
+				// the wakeup may have been meant for a Get
+				p.signalWaiterLocked()
+			}
+			p.lock.Unlock()
+			return nil
+		}
+		if max := p.opts.maxWaiters; max > 0 && p.waitersLocked() >= max {
+			if woken {
+				p.signalWaiterLocked()
+			}
+			p.lock.Unlock()
+			return ErrTooManyWaiters
+		}
+		ch, ele := p.addWaiterLocked()
+		p.lock.Unlock()
+		if err := p.awaitWake(ctx, ch, ele); err != nil {
+			return err
+		}
+		woken = true
+	}
+}
@@ -0,0 +1,88 @@
+package thriftpool_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/lehaisonmath6/thriftpool"
+	"github.com/lehaisonmath6/thriftpool/thriftpooltest"
+)
+
+func TestMaxWaitersRejectsNextWaiter(t *testing.T) {
+	const max = 3
+	d := &thriftpooltest.Dialer{}
+	p := thriftpool.NewThriftPool("127.0.0.1", "9090", 1, 1, 10, d.Dial, nil,
+		thriftpool.WithOverflowPolicy(thriftpool.Block), thriftpool.WithMaxWaiters(max))
+	defer p.Release()
+
+	c, err := p.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	served := make(chan error, max)
+	for i := 0; i < max; i++ {
+		go func() {
+			c, err := p.Get()
+			if err == nil {
+				p.Put(c)
+			}
+			served <- err
+		}()
+	}
+	for p.Stats().Waiters < max {
+		time.Sleep(time.Millisecond)
+	}
+
+	start := time.Now()
+	_, err = p.Get()
+	if err != thriftpool.ErrTooManyWaiters {
+		t.Fatalf("Get with %d waiters queued = %v, want ErrTooManyWaiters", max, err)
+	}
+	if el := time.Since(start); el > 50*time.Millisecond {
+		t.Errorf("rejected Get took %v, want it to fail fast", el)
+	}
+	if !thriftpool.IsTransient(err) {
+		t.Error("ErrTooManyWaiters is not transient")
+	}
+	if s := p.Stats(); s.Waiters != max || s.MaxWaiters != max {
+		t.Errorf("Stats reports %d of %d waiters, want %d of %d", s.Waiters, s.MaxWaiters, max, max)
+	}
+
+	p.Put(c)
+	for i := 0; i < max; i++ {
+		if err := <-served; err != nil {
+			t.Errorf("queued Get: %v", err)
+		}
+	}
+}
+
+func TestWaitForCapacityHonorsMaxWaitersWithoutWaitStats(t *testing.T) {
+	d := &thriftpooltest.Dialer{}
+	p := thriftpool.NewThriftPool("127.0.0.1", "9090", 1, 1, 10, d.Dial, nil,
+		thriftpool.WithOverflowPolicy(thriftpool.Block), thriftpool.WithMaxWaiters(1))
+	defer p.Release()
+
+	c, err := p.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	admitted := make(chan error, 1)
+	go func() { admitted <- p.WaitForCapacity(context.Background()) }()
+	for p.Stats().Waiters < 1 {
+		time.Sleep(time.Millisecond)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := p.WaitForCapacity(ctx); err != thriftpool.ErrTooManyWaiters {
+		t.Fatalf("WaitForCapacity with the queue full = %v, want ErrTooManyWaiters", err)
+	}
+
+	p.Put(c)
+	if err := <-admitted; err != nil {
+		t.Fatalf("queued WaitForCapacity: %v", err)
+	}
+	if n := p.Stats().WaitCount; n != 0 {
+		t.Errorf("WaitCount %d after WaitForCapacity alone waited, want 0", n)
+	}
+}
@@ -0,0 +1,142 @@
+package thriftpool
+
+import (
+	"context"
+	"time"
+)
+
+// addIdle dials one connection straight into the idle list.
+func (p *ThriftPool) addIdle() error {
+	p.lock.Lock()
+	if p.closed {
+		p.lock.Unlock()
+		return ErrPoolClosed
+	}
+	if p.fullLocked() || !p.takeSlotLocked() {
+		p.lock.Unlock()
+		return ErrOverMax
+	}
+	p.lock.Unlock()
+
+	return p.dialIdle(p.baseContext())
+}
+
+// dialIdle dials a connection into the idle list using a slot the caller has
+// already reserved.
+func (p *ThriftPool) dialIdle(ctx context.Context) error {
+	ip, port, err := p.pickAddr()
+	if err != nil {
+		p.lock.Lock()
+		p.releaseSlotLocked(false)
+		p.unlock()
+		return err
+	}
+	client, err := p.open(ctx, ip, port, false)
+	if err != nil {
+		return err
+	}
+
+	p.lock.Lock()
+	if p.closed {
+		p.releaseSlotLocked(false)
+		p.unlock()
+		p.closing(client, causeShutdown)
+		p.closeClient(client)
+		return ErrPoolClosed
+	}
+	p.pushIdle(client)
+	p.lock.Unlock()
+	p.setState(client, StateIdle)
+	return nil
+}
+
+func (p *ThriftPool) idleBelowMin() bool {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return uint32(p.idle.Len()) < p.minIdleLocked()
+}
+
+// fillIdle dials connections straight into the idle list until it holds
+// minIdle of them or the pool is full.
+func (p *ThriftPool) fillIdle() {
+	for p.idleBelowMin() {
+		if err := p.addIdle(); err != nil {
+			if err != ErrOverMax && err != ErrPoolClosed {
+				p.logf("thriftpool: dial to fill idle connections: %v", err)
+			}
+			return
+		}
+	}
+}
+
+// Warmup dials connections until minIdle are idle. It stops at the first
+// error, which is ErrOverMax if maxConn is reached first.
+func (p *ThriftPool) Warmup() error {
+	for p.idleBelowMin() {
+		if err := p.addIdle(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WarmupGradual dials up to target idle connections spread evenly over the
+// over window, so the backend sees a ramp instead of a burst. It stops at the
+// first error, including ErrCircuitOpen, and returns how many connections it
+// established.
+func (p *ThriftPool) WarmupGradual(target uint32, over time.Duration) (int, error) {
+	if target == 0 {
+		return 0, nil
+	}
+	interval := over / time.Duration(target)
+
+	p.lock.Lock()
+	stop := p.stop
+	p.lock.Unlock()
+
+	n := 0
+	for i := uint32(0); i < target; i++ {
+		if i > 0 && interval > 0 {
+			select {
+			case <-stop:
+				return n, ErrPoolClosed
+			case <-time.After(interval):
+			}
+		}
+		if err := p.addIdle(); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// WaitReady blocks until at least n connections are idle. It returns
+// ctx.Err() if ctx ends first, and ErrPoolClosed if the pool is or gets
+// released.
+func (p *ThriftPool) WaitReady(ctx context.Context, n uint32) error {
+	for {
+		p.lock.Lock()
+		if p.closed {
+			p.lock.Unlock()
+			return ErrPoolClosed
+		}
+		if uint32(p.idle.Len()) >= n {
+			p.lock.Unlock()
+			return nil
+		}
+		if p.idleAdded == nil {
+			p.idleAdded = make(chan struct{})
+		}
+		added, stop := p.idleAdded, p.stop
+		p.lock.Unlock()
+
+		select {
+		case <-added:
+		case <-stop:
+			return ErrPoolClosed
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package thriftpool
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// WINDOWBUCKETS is how many one-second buckets WindowedStats keeps, and so
+// the longest window it can report on.
+const WINDOWBUCKETS = 60
+
+type statField int
+
+const (
+	statGets statField = iota
+	statHits
+	statMisses
+	statTimeouts
+	statDialErrors
+	// statDials counts successful dials, for Health
+	statDials
+	// statGetErrors counts failed Gets, for AcquireSuccessRate
+	statGetErrors
+	// statWaits counts Gets that queued for a full pool, for SuggestMaxConn
+	statWaits
+	numStatFields
+)
+
+// window counts recent events in a ring of per-second buckets. A bucket is
+// reset by the first event of a new second, so counts racing with the reset
+// may be lost; the numbers are approximate by design.
+type window struct {
+	buckets [WINDOWBUCKETS]bucket
+}
+
+type bucket struct {
+	sec int64
+	n   [numStatFields]uint64
+	// peak is the most connections borrowed at once during the second
+	peak uint32
+}
+
+// bucket returns the bucket for now, resetting it if it last held an older
+// second.
+func (w *window) bucket(now time.Time) *bucket {
+	sec := now.Unix()
+	b := &w.buckets[sec%WINDOWBUCKETS]
+	if old := atomic.LoadInt64(&b.sec); old != sec && atomic.CompareAndSwapInt64(&b.sec, old, sec) {
+		for i := range b.n {
+			atomic.StoreUint64(&b.n[i], 0)
+		}
+		atomic.StoreUint32(&b.peak, 0)
+	}
+	return b
+}
+
+func (w *window) add(f statField, now time.Time) {
+	atomic.AddUint64(&w.bucket(now).n[f], 1)
+}
+
+// observe records inUse borrowed connections for the window's peak.
+func (w *window) observe(inUse uint32, now time.Time) {
+	b := w.bucket(now)
+	for {
+		old := atomic.LoadUint32(&b.peak)
+		if inUse <= old || atomic.CompareAndSwapUint32(&b.peak, old, inUse) {
+			return
+		}
+	}
+}
+
+// peak is the most connections borrowed at once over the last d.
+func (w *window) peak(now time.Time, d time.Duration) uint32 {
+	var peak uint32
+	w.each(now, d, func(b *bucket) {
+		if n := atomic.LoadUint32(&b.peak); n > peak {
+			peak = n
+		}
+	})
+	return peak
+}
+
+// each calls fn on the buckets of the last d that are current.
+func (w *window) each(now time.Time, d time.Duration, fn func(*bucket)) {
+	secs := int64((d + time.Second - 1) / time.Second)
+	if secs < 1 {
+		secs = 1
+	} else if secs > WINDOWBUCKETS {
+		secs = WINDOWBUCKETS
+	}
+	for sec := now.Unix(); sec > now.Unix()-secs; sec-- {
+		b := &w.buckets[sec%WINDOWBUCKETS]
+		if atomic.LoadInt64(&b.sec) == sec {
+			fn(b)
+		}
+	}
+}
+
+func (w *window) sum(now time.Time, d time.Duration) [numStatFields]uint64 {
+	var total [numStatFields]uint64
+	w.each(now, d, func(b *bucket) {
+		for i := range total {
+			total[i] += atomic.LoadUint64(&b.n[i])
+		}
+	})
+	return total
+}
+
+// WindowedStats is Stats with Gets, Hits, Misses, Timeouts and DialErrors
+// counted over the last d, rounded up to whole seconds and capped at
+// WINDOWBUCKETS seconds, instead of since the pool was created. The other
+// counters are left zero; the gauges are current.
+func (p *ThriftPool) WindowedStats(d time.Duration) Stats {
+	p.lock.RLock()
+	s := p.statsLocked()
+	p.lock.RUnlock()
+
+	n := p.stats.window.sum(p.now(), d)
+	return Stats{
+		Idle:            s.Idle,
+		Active:          s.Active,
+		MaxConn:         s.MaxConn,
+		Overflow:        s.Overflow,
+		OpenConnections: s.OpenConnections,
+		InUse:           s.InUse,
+
+		Gets:       n[statGets],
+		Hits:       n[statHits],
+		Misses:     n[statMisses],
+		Timeouts:   n[statTimeouts],
+		DialErrors: n[statDialErrors],
+	}
+}
+
+// AcquireSuccessRate is the fraction of Gets over the last d, as
+// WindowedStats counts it, that returned a connection. With no Gets in the
+// window it is 1, so an idle pool doesn't look like a failing one. It only
+// reads the counters, so it is cheap to poll.
+func (p *ThriftPool) AcquireSuccessRate(d time.Duration) float64 {
+	n := p.stats.window.sum(p.now(), d)
+	gets, failed := n[statGets], n[statGetErrors]
+	if gets == 0 {
+		return 1
+	}
+	if failed > gets {
+		// a failure counted in a bucket reset under it
+		failed = gets
+	}
+	return float64(gets-failed) / float64(gets)
+}